@@ -0,0 +1,180 @@
+package main
+
+import (
+   "bufio"
+   "bytes"
+   "crypto/ecdsa"
+   "crypto/elliptic"
+   "crypto/rand"
+   "crypto/tls"
+   "crypto/x509"
+   "encoding/hex"
+   "errors"
+   "fmt"
+   "github.com/89z/rosso/crypto"
+   "io"
+   "math/big"
+   "net"
+   "net/http"
+   "os"
+   "strings"
+   "sync"
+   "time"
+)
+
+type server struct {
+   config *tls.Config
+   mu sync.Mutex
+   output string
+}
+
+func new_server(output string) (*server, error) {
+   cert, err := self_signed()
+   if err != nil {
+      return nil, err
+   }
+   var s server
+   s.config = &tls.Config{
+      Certificates: []tls.Certificate{*cert},
+      NextProtos: []string{"http/1.1"},
+   }
+   s.output = output
+   return &s, nil
+}
+
+func (s *server) serve(ln net.Listener) error {
+   for {
+      conn, err := ln.Accept()
+      if err != nil {
+         return err
+      }
+      go func() {
+         defer conn.Close()
+         if err := s.handle(conn); err != nil {
+            os.Stderr.WriteString(err.Error() + "\n")
+         }
+      }()
+   }
+}
+
+// the ClientHello is read before crypto/tls sees it, then replayed so the
+// handshake can finish and the client gets its fingerprint back
+func (s *server) handle(conn net.Conn) error {
+   conn.SetDeadline(time.Now().Add(9 * time.Second))
+   hello, err := read_record(conn)
+   if err != nil {
+      return err
+   }
+   report, err := s.report(conn.RemoteAddr(), hello)
+   if err != nil {
+      return err
+   }
+   replay := replay_conn{conn, io.MultiReader(bytes.NewReader(hello), conn)}
+   tconn := tls.Server(replay, s.config)
+   if err := tconn.Handshake(); err != nil {
+      return err
+   }
+   req, err := http.ReadRequest(bufio.NewReader(tconn))
+   if err != nil {
+      return err
+   }
+   res := http.Response{
+      Body: io.NopCloser(strings.NewReader(report)),
+      ContentLength: int64(len(report)),
+      Header: http.Header{"Content-Type": {"text/plain"}},
+      ProtoMajor: 1,
+      ProtoMinor: 1,
+      Request: req,
+      StatusCode: http.StatusOK,
+   }
+   return res.Write(tconn)
+}
+
+func (s *server) report(addr net.Addr, hello []byte) (string, error) {
+   spec, err := crypto.Parse_TLS(hello)
+   if err != nil {
+      return "", err
+   }
+   ja3, err := crypto.Format_JA3(spec)
+   if err != nil {
+      return "", err
+   }
+   ja4, err := crypto.Format_JA4(spec)
+   if err != nil {
+      return "", err
+   }
+   var b strings.Builder
+   fmt.Fprintln(&b, "ja3:", ja3)
+   fmt.Fprintln(&b, "ja3_hash:", crypto.Fingerprint(ja3))
+   fmt.Fprintln(&b, "ja4:", ja4)
+   fmt.Fprintf(&b, "version: %v-%v\n", spec.TLSVersMin, spec.TLSVersMax)
+   fmt.Fprintln(&b, "cipher suites:", spec.CipherSuites)
+   fmt.Fprintln(&b, "compression methods:", spec.CompressionMethods)
+   for _, ext := range spec.Extensions {
+      fmt.Fprintf(&b, "%T %+v\n", ext, ext)
+   }
+   s.mu.Lock()
+   defer s.mu.Unlock()
+   fmt.Println(addr)
+   fmt.Print(b.String())
+   if s.output != "" {
+      file, err := os.OpenFile(
+         s.output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644,
+      )
+      if err != nil {
+         return "", err
+      }
+      defer file.Close()
+      _, err = fmt.Fprintln(file, hex.EncodeToString(hello))
+      if err != nil {
+         return "", err
+      }
+   }
+   return b.String(), nil
+}
+
+type replay_conn struct {
+   net.Conn
+   r io.Reader
+}
+
+func (r replay_conn) Read(buf []byte) (int, error) {
+   return r.r.Read(buf)
+}
+
+// one TLS record, with the header
+func read_record(r io.Reader) ([]byte, error) {
+   head := make([]byte, 5)
+   if _, err := io.ReadFull(r, head); err != nil {
+      return nil, err
+   }
+   if head[0] != 0x16 {
+      return nil, errors.New("not a TLS handshake")
+   }
+   body := make([]byte, int(head[3]) << 8 | int(head[4]))
+   if _, err := io.ReadFull(r, body); err != nil {
+      return nil, err
+   }
+   return append(head, body...), nil
+}
+
+func self_signed() (*tls.Certificate, error) {
+   key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+   if err != nil {
+      return nil, err
+   }
+   template := x509.Certificate{
+      DNSNames: []string{"localhost"},
+      IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+      NotAfter: time.Now().AddDate(1, 0, 0),
+      NotBefore: time.Now().Add(-time.Hour),
+      SerialNumber: big.NewInt(1),
+   }
+   der, err := x509.CreateCertificate(
+      rand.Reader, &template, &template, &key.PublicKey, key,
+   )
+   if err != nil {
+      return nil, err
+   }
+   return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
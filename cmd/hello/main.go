@@ -0,0 +1,33 @@
+package main
+
+import (
+   "flag"
+   "net"
+   "os"
+)
+
+func main() {
+   // a
+   var address string
+   flag.StringVar(&address, "a", "", "listen address, for example :8443")
+   // o
+   var output string
+   flag.StringVar(&output, "o", "", "append ClientHello hex to file")
+   flag.Parse()
+   if address != "" {
+      srv, err := new_server(output)
+      if err != nil {
+         panic(err)
+      }
+      ln, err := net.Listen("tcp", address)
+      if err != nil {
+         panic(err)
+      }
+      os.Stderr.WriteString("Listen " + ln.Addr().String() + "\n")
+      if err := srv.serve(ln); err != nil {
+         panic(err)
+      }
+   } else {
+      flag.Usage()
+   }
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+   "encoding/json"
+   "flag"
+   "github.com/89z/rosso/mp4"
+   "github.com/89z/rosso/os"
+)
+
+func do_inspect(input, output string) error {
+   in, err := os.Open(input)
+   if err != nil {
+      return err
+   }
+   defer in.Close()
+   out, err := os.Create(output)
+   if err != nil {
+      out = os.Stdout
+   }
+   defer out.Close()
+   pro, err := mp4.Inspect(in)
+   if err != nil {
+      return err
+   }
+   enc := json.NewEncoder(out)
+   enc.SetEscapeHTML(false)
+   enc.SetIndent("", " ")
+   return enc.Encode(pro)
+}
+
+func main() {
+   // f
+   var input string
+   flag.StringVar(&input, "f", "", "input file")
+   // o
+   var output string
+   flag.StringVar(&output, "o", "", "output file")
+   flag.Parse()
+   if input != "" {
+      err := do_inspect(input, output)
+      if err != nil {
+         panic(err)
+      }
+   } else {
+      flag.Usage()
+   }
+}
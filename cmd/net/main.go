@@ -2,15 +2,19 @@ package main
 
 import (
    "flag"
+   "github.com/89z/rosso/crypto"
    "github.com/89z/rosso/http"
    "os"
+   "strings"
 )
 
 type flags struct {
+   akamai string
    golang bool
    https bool
    name string
    output string
+   preset string
 }
 
 func main() {
@@ -19,8 +23,15 @@ func main() {
    flag.StringVar(&f.name, "f", "", "input file")
    // g
    flag.BoolVar(&f.golang, "g", false, "request as Go code")
+   // k
+   flag.StringVar(&f.akamai, "k", "", "Akamai HTTP/2 fingerprint, with -p")
    // o
    flag.StringVar(&f.output, "o", "", "output file")
+   // p
+   flag.StringVar(
+      &f.preset, "p", "",
+      "TLS preset: " + strings.Join(crypto.Preset_Names(), ", "),
+   )
    // s
    flag.BoolVar(&f.https, "s", false, "HTTPS")
    flag.Parse()
@@ -52,8 +63,21 @@ func main() {
             panic(err)
          }
       } else {
-         err := write(req, create)
-         if err != nil {
+         var tr http.RoundTripper = new(http.Transport)
+         if f.preset != "" {
+            r, err := crypto.Preset_Round_Tripper(f.preset)
+            if err != nil {
+               panic(err)
+            }
+            if f.akamai != "" {
+               r.H2, err = crypto.Parse_Akamai(f.akamai)
+               if err != nil {
+                  panic(err)
+               }
+            }
+            tr = r
+         }
+         if err := write(req, tr, create); err != nil {
             panic(err)
          }
       }
@@ -12,8 +12,8 @@ import (
    "text/template"
 )
 
-func write(req *http.Request, file *os.File) error {
-   res, err := new(http.Transport).RoundTrip(req)
+func write(req *http.Request, tr http.RoundTripper, file *os.File) error {
+   res, err := tr.RoundTrip(req)
    if err != nil {
       return err
    }
@@ -0,0 +1,175 @@
+package crypto
+
+import (
+   "errors"
+   "golang.org/x/net/http2"
+   "strconv"
+   "strings"
+)
+
+// what a HTTP/2 client sends before and with its first request, which
+// servers use as a fingerprint
+type H2_Fingerprint struct {
+   // PRIORITY frames sent after the connection preface
+   Priorities []H2_Priority
+   // order of :method, :authority, :scheme and :path, as m, a, s and p
+   Pseudo_Headers []string
+   // SETTINGS frame, in order
+   Settings []http2.Setting
+   // connection WINDOW_UPDATE increment, zero for none
+   Window_Update uint32
+   // priority of each request HEADERS frame, not part of the Akamai string
+   Headers_Priority http2.PriorityParam
+}
+
+type H2_Priority struct {
+   Stream_ID uint32
+   http2.PriorityParam
+}
+
+// Google Chrome 106
+const Chrome_Akamai = "1:65536;2:0;3:1000;4:6291456;6:262144|15663105|0|m,a,s,p"
+
+// Mozilla Firefox 105
+const Firefox_Akamai =
+   "1:65536;4:131072;5:16384|12517377|3:0:0:201,5:0:0:101,7:0:0:1," +
+   "9:0:7:1,11:0:3:1,13:0:0:241|m,p,a,s"
+
+// what Round_Tripper sends if H2 is nil
+var default_h2 = H2_Fingerprint{
+   Pseudo_Headers: []string{"m", "a", "s", "p"},
+   Settings: []http2.Setting{
+      {ID: http2.SettingEnablePush, Val: 0},
+      {ID: http2.SettingInitialWindowSize, Val: 4 << 20},
+   },
+   Window_Update: 1 << 30 - 65535,
+}
+
+// SETTINGS|WINDOW_UPDATE|PRIORITY|Pseudo-Header-Order, from "Passive
+// Fingerprinting of HTTP/2 Clients", see readme.md
+func Format_Akamai(h *H2_Fingerprint) string {
+   var b []byte
+   for key, set := range h.Settings {
+      if key >= 1 {
+         b = append(b, ';')
+      }
+      b = strconv.AppendUint(b, uint64(set.ID), 10)
+      b = append(b, ':')
+      b = strconv.AppendUint(b, uint64(set.Val), 10)
+   }
+   b = append(b, '|')
+   if h.Window_Update >= 1 {
+      b = strconv.AppendUint(b, uint64(h.Window_Update), 10)
+   } else {
+      b = append(b, "00"...)
+   }
+   b = append(b, '|')
+   for key, pri := range h.Priorities {
+      if key >= 1 {
+         b = append(b, ',')
+      }
+      b = strconv.AppendUint(b, uint64(pri.Stream_ID), 10)
+      if pri.Exclusive {
+         b = append(b, ":1:"...)
+      } else {
+         b = append(b, ":0:"...)
+      }
+      b = strconv.AppendUint(b, uint64(pri.StreamDep), 10)
+      b = append(b, ':')
+      // the weight on the wire is one less
+      b = strconv.AppendUint(b, uint64(pri.Weight) + 1, 10)
+   }
+   if h.Priorities == nil {
+      b = append(b, '0')
+   }
+   b = append(b, '|')
+   b = append(b, strings.Join(h.Pseudo_Headers, ",")...)
+   return string(b)
+}
+
+func Parse_Akamai(s string) (*H2_Fingerprint, error) {
+   fields := strings.Split(s, "|")
+   if len(fields) != 4 {
+      return nil, errors.New("Akamai fingerprint needs 4 fields " + s)
+   }
+   var h H2_Fingerprint
+   // settings are separated with semicolon, or sometimes comma
+   sets := strings.FieldsFunc(fields[0], func(r rune) bool {
+      return r == ';' || r == ','
+   })
+   for _, set := range sets {
+      nums, err := parse_uints(set, 2)
+      if err != nil {
+         return nil, err
+      }
+      h.Settings = append(h.Settings, http2.Setting{
+         ID: http2.SettingID(nums[0]), Val: uint32(nums[1]),
+      })
+   }
+   update, err := strconv.ParseUint(fields[1], 10, 31)
+   if err != nil {
+      return nil, err
+   }
+   h.Window_Update = uint32(update)
+   if fields[2] != "0" {
+      for _, pri := range strings.Split(fields[2], ",") {
+         nums, err := parse_uints(pri, 4)
+         if err != nil {
+            return nil, err
+         }
+         if nums[1] >= 2 || nums[3] == 0 || nums[3] > 256 {
+            return nil, errors.New("invalid priority " + pri)
+         }
+         var p H2_Priority
+         p.Stream_ID = uint32(nums[0])
+         p.Exclusive = nums[1] == 1
+         p.StreamDep = uint32(nums[2])
+         p.Weight = uint8(nums[3] - 1)
+         h.Priorities = append(h.Priorities, p)
+      }
+   }
+   // each request needs all four
+   seen := make(map[string]bool)
+   for _, pseudo := range strings.Split(fields[3], ",") {
+      if pseudo_header(pseudo) == "" || seen[pseudo] {
+         return nil, errors.New("invalid pseudo header " + pseudo)
+      }
+      seen[pseudo] = true
+      h.Pseudo_Headers = append(h.Pseudo_Headers, pseudo)
+   }
+   if len(seen) != 4 {
+      return nil, errors.New("incomplete pseudo headers " + fields[3])
+   }
+   return &h, nil
+}
+
+// for example 3:0:0:201
+func parse_uints(s string, n int) ([]uint64, error) {
+   fields := strings.Split(s, ":")
+   if len(fields) != n {
+      return nil, errors.New("invalid field " + s)
+   }
+   var nums []uint64
+   for _, field := range fields {
+      num, err := strconv.ParseUint(field, 10, 32)
+      if err != nil {
+         return nil, err
+      }
+      nums = append(nums, num)
+   }
+   return nums, nil
+}
+
+func pseudo_header(s string) string {
+   switch s {
+   case "m":
+      return ":method"
+   case "a":
+      return ":authority"
+   case "s":
+      return ":scheme"
+   case "p":
+      return ":path"
+   }
+   return ""
+}
@@ -0,0 +1,195 @@
+package crypto
+
+import (
+   "crypto/tls"
+   "golang.org/x/net/http2"
+   "golang.org/x/net/http2/hpack"
+   "io"
+   "net"
+   "net/http"
+   "net/http/httptest"
+   "strings"
+   "testing"
+)
+
+func Test_Akamai(t *testing.T) {
+   for _, in := range []string{Chrome_Akamai, Firefox_Akamai} {
+      h, err := Parse_Akamai(in)
+      if err != nil {
+         t.Fatal(err)
+      }
+      if out := Format_Akamai(h); out != in {
+         t.Fatal(out)
+      }
+   }
+   h, err := Parse_Akamai("1:65536,3:1000|00|0|m,a,s,p")
+   if err != nil {
+      t.Fatal(err)
+   }
+   if out := Format_Akamai(h); out != "1:65536;3:1000|00|0|m,a,s,p" {
+      t.Fatal(out)
+   }
+   bad := []string{
+      "1:65536|15663105|0",
+      "1:65536|15663105|3:0:0:0|m,a,s,p",
+      "1:65536|15663105|3:2:0:201|m,a,s,p",
+      "1:65536|15663105|0|m,a,x,p",
+      "1:65536|15663105|0|m,a",
+      "1:65536|15663105|0|m,a,s,p,m",
+      "1:65536|15663105|0|m,a,a,p",
+      "1|15663105|0|m,a,s,p",
+   }
+   for _, in := range bad {
+      if _, err := Parse_Akamai(in); err == nil {
+         t.Fatal(in)
+      }
+   }
+}
+
+// the frames are read back on the server, before any response
+func Test_Akamai_Frames(t *testing.T) {
+   server := new_echo_server(true)
+   defer server.Close()
+   ln, err := net.Listen("tcp", "127.0.0.1:0")
+   if err != nil {
+      t.Fatal(err)
+   }
+   defer ln.Close()
+   prints := make(chan string, 1)
+   go func() {
+      conn, err := tls.NewListener(ln, server.TLS).Accept()
+      if err != nil {
+         prints <- err.Error()
+         return
+      }
+      defer conn.Close()
+      print, err := read_akamai(conn)
+      if err != nil {
+         prints <- err.Error()
+         return
+      }
+      prints <- print
+   }()
+   r := new_round_tripper(t, server, "firefox_102")
+   r.H2, err = Parse_Akamai(Firefox_Akamai)
+   if err != nil {
+      t.Fatal(err)
+   }
+   req, err := http.NewRequest("GET", "https://" + ln.Addr().String(), nil)
+   if err != nil {
+      t.Fatal(err)
+   }
+   go func() {
+      res, err := r.RoundTrip(req)
+      if err == nil {
+         res.Body.Close()
+      }
+   }()
+   if print := <-prints; print != Firefox_Akamai {
+      t.Fatal(print)
+   }
+}
+
+func read_akamai(conn net.Conn) (string, error) {
+   preface := make([]byte, len(http2.ClientPreface))
+   if _, err := io.ReadFull(conn, preface); err != nil {
+      return "", err
+   }
+   framer := http2.NewFramer(io.Discard, conn)
+   framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+   var h H2_Fingerprint
+   for {
+      frame, err := framer.ReadFrame()
+      if err != nil {
+         return "", err
+      }
+      switch f := frame.(type) {
+      case *http2.SettingsFrame:
+         f.ForeachSetting(func(set http2.Setting) error {
+            h.Settings = append(h.Settings, set)
+            return nil
+         })
+      case *http2.WindowUpdateFrame:
+         h.Window_Update = f.Increment
+      case *http2.PriorityFrame:
+         h.Priorities = append(h.Priorities, H2_Priority{
+            f.StreamID, f.PriorityParam,
+         })
+      case *http2.MetaHeadersFrame:
+         for _, field := range f.PseudoFields() {
+            name := strings.TrimPrefix(field.Name, ":")
+            h.Pseudo_Headers = append(h.Pseudo_Headers, name[:1])
+         }
+         return Format_Akamai(&h), nil
+      }
+   }
+}
+
+func Test_Akamai_Round_Trip(t *testing.T) {
+   server := new_echo_server(true)
+   defer server.Close()
+   for _, print := range []string{Chrome_Akamai, Firefox_Akamai} {
+      r := new_round_tripper(t, server, "chrome_102")
+      var err error
+      r.H2, err = Parse_Akamai(print)
+      if err != nil {
+         t.Fatal(err)
+      }
+      for i := 0; i < 2; i++ {
+         req, err := http.NewRequest(
+            "POST", server.URL, strings.NewReader("hello world"),
+         )
+         if err != nil {
+            t.Fatal(err)
+         }
+         res, err := r.RoundTrip(req)
+         if err != nil {
+            t.Fatal(print, err)
+         }
+         body, err := io.ReadAll(res.Body)
+         if err != nil {
+            t.Fatal(err)
+         }
+         res.Body.Close()
+         if res.Proto != "HTTP/2.0" || string(body) != "hello world" {
+            t.Fatal(print, res.Proto, string(body))
+         }
+      }
+   }
+}
+
+// Firefox_Akamai does not disable push, so the PUSH_PROMISE has to be
+// refused, even before the first response HEADERS
+func Test_Akamai_Push(t *testing.T) {
+   pushes := make(chan error, 4)
+   server := httptest.NewUnstartedServer(http.HandlerFunc(
+      func(w http.ResponseWriter, r *http.Request) {
+         if r.URL.Path == "/" {
+            pushes <- w.(http.Pusher).Push("/pushed", nil)
+         }
+         io.WriteString(w, "hello world")
+      },
+   ))
+   server.EnableHTTP2 = true
+   server.StartTLS()
+   defer server.Close()
+   r := new_round_tripper(t, server, "firefox_102")
+   defer r.CloseIdleConnections()
+   for i := 0; i < 2; i++ {
+      res, err := r.RoundTrip(get(t, server.URL))
+      if err != nil {
+         t.Fatal(err)
+      }
+      body, err := io.ReadAll(res.Body)
+      if err != nil {
+         t.Fatal(err)
+      }
+      res.Body.Close()
+      if string(body) != "hello world" {
+         t.Fatal(string(body))
+      }
+      if err := <-pushes; err != nil {
+         t.Fatal("push", err)
+      }
+   }
+}
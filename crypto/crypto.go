@@ -5,11 +5,13 @@ import (
    "crypto/md5"
    "encoding/binary"
    "encoding/hex"
+   "errors"
    "github.com/89z/rosso/strconv"
    "github.com/refraction-networking/utls"
    "io"
    "net"
    "net/http"
+   "net/url"
 )
 
 func extension_type(ext tls.TLSExtension) (uint16, error) {
@@ -27,8 +29,13 @@ func extension_type(ext tls.TLSExtension) (uint16, error) {
 
 func Format_JA3(spec *tls.ClientHelloSpec) (string, error) {
    var b []byte
-   // TLSVersMin is the record version, TLSVersMax is the handshake version
-   b = strconv.AppendUint(b, spec.TLSVersMax, 10)
+   // TLSVersMin is the record version, TLSVersMax is the handshake version,
+   // which is locked at TLS 1.2 since TLS 1.3
+   vers := spec.TLSVersMax
+   if vers > tls.VersionTLS12 {
+      vers = tls.VersionTLS12
+   }
+   b = strconv.AppendUint(b, vers, 10)
    // Cipher Suites
    b = append(b, ',')
    for key, val := range spec.CipherSuites {
@@ -78,32 +85,76 @@ func Format_JA3(spec *tls.ClientHelloSpec) (string, error) {
    return string(b), nil
 }
 
-// cannot call pointer method RoundTrip on http.Transport
+// cannot call pointer method RoundTrip on http.Transport. RootCAs and
+// InsecureSkipVerify are taken from TLSClientConfig, if set. The proxy is
+// from Proxy_From_Environment, so unlike http.ProxyFromEnvironment, HTTPS
+// goes through HTTP_PROXY if HTTPS_PROXY is not set. HTTP/1.1 only: if the
+// server selects h2 with ALPN, RoundTrip returns an error, so use
+// Round_Tripper for HTTP/2.
 func Transport(spec *tls.ClientHelloSpec) *http.Transport {
+   return Proxy_Transport(spec, Proxy_From_Environment)
+}
+
+// HTTPS goes through the proxy before the uTLS handshake, with either HTTP
+// CONNECT or SOCKS5. Setting Proxy on the result only changes http URLs.
+func Proxy_Transport(
+   spec *tls.ClientHelloSpec, proxy func(*http.Request) (*url.URL, error),
+) *http.Transport {
+   return transport(func() *tls.ClientHelloSpec {
+      return spec
+   }, proxy)
+}
+
+// HTTP/1.1 only, see Round_Tripper for HTTP/2
+func transport(
+   preset Preset, proxy func(*http.Request) (*url.URL, error),
+) *http.Transport {
    var tr http.Transport
+   tr.Proxy = http_proxy(proxy)
    //lint:ignore SA1019 godocs.io/context
    tr.DialTLS = func(network, ref string) (net.Conn, error) {
-      conn, err := net.Dial(network, ref)
-      if err != nil {
-         return nil, err
-      }
-      host, _, err := net.SplitHostPort(ref)
+      uconn, err := dial_tls(&tr, preset, proxy, network, ref)
       if err != nil {
          return nil, err
       }
-      config := &tls.Config{ServerName: host}
-      uconn := tls.UClient(conn, config, tls.HelloCustom)
-      if err := uconn.ApplyPreset(spec); err != nil {
-         return nil, err
-      }
-      if err := uconn.Handshake(); err != nil {
-         return nil, err
+      if uconn.ConnectionState().NegotiatedProtocol == "h2" {
+         uconn.Close()
+         return nil, errors.New("server selected h2, use Round_Tripper")
       }
       return uconn, nil
    }
    return &tr
 }
 
+func dial_tls(
+   tr *http.Transport, preset Preset,
+   proxy func(*http.Request) (*url.URL, error), network, ref string,
+) (*tls.UConn, error) {
+   conn, err := dial_proxy(proxy, network, ref)
+   if err != nil {
+      return nil, err
+   }
+   host, _, err := net.SplitHostPort(ref)
+   if err != nil {
+      return nil, err
+   }
+   config := &tls.Config{ServerName: host}
+   if tr.TLSClientConfig != nil {
+      config.InsecureSkipVerify = tr.TLSClientConfig.InsecureSkipVerify
+      config.RootCAs = tr.TLSClientConfig.RootCAs
+   }
+   uconn := tls.UClient(conn, config, tls.HelloCustom)
+   if err := uconn.ApplyPreset(preset()); err != nil {
+      conn.Close()
+      return nil, err
+   }
+   if err := uconn.Handshake(); err != nil {
+      conn.Close()
+      return nil, err
+   }
+   return uconn, nil
+}
+
 // len 122, 8fcaa9e4a15f48af0a7d396e3fa5c5eb
 const Android_API_24 =
    "771,49195-49196-52393-49199-49200-52392-158-159-49161-49162-49171-" +
@@ -0,0 +1,615 @@
+package crypto
+
+import (
+   "bytes"
+   "errors"
+   "golang.org/x/net/http2"
+   "golang.org/x/net/http2/hpack"
+   "io"
+   "net"
+   "net/http"
+   "sort"
+   "strconv"
+   "strings"
+   "sync"
+)
+
+// HTTP/2 client side of one connection. Frames are read by read_loop, and
+// written by whoever holds write_mu. Everything else is guarded by mu, and
+// cond is broadcast on any change.
+type h2_conn struct {
+   cond *sync.Cond
+   conn net.Conn
+   enc *hpack.Encoder
+   enc_buf bytes.Buffer
+   err error
+   framer *http2.Framer
+   // no new streams after GOAWAY
+   goaway bool
+   initial_window int32
+   max_frame uint32
+   max_streams uint32
+   mu sync.Mutex
+   next_id uint32
+   print *H2_Fingerprint
+   reserved int
+   send_window int32
+   streams map[uint32]*h2_stream
+   write_mu sync.Mutex
+}
+
+type h2_stream struct {
+   body bytes.Buffer
+   conn *h2_conn
+   // END_STREAM received
+   done bool
+   err error
+   // closed once the stream is done or failed
+   finished chan struct{}
+   id uint32
+   // closed once res or err is set
+   ready chan struct{}
+   res *http.Response
+   send_window int32
+}
+
+// received data is credited back to the server as the body is read, so the
+// window sizes in print only set how much can be buffered
+func new_h2_conn(conn net.Conn, print *H2_Fingerprint) (*h2_conn, error) {
+   c := h2_conn{
+      conn: conn,
+      // RFC 9113 section 6.5.2
+      initial_window: 65535,
+      max_frame: 16384,
+      max_streams: 100,
+      next_id: 1,
+      print: print,
+      send_window: 65535,
+      streams: make(map[uint32]*h2_stream),
+   }
+   c.cond = sync.NewCond(&c.mu)
+   c.enc = hpack.NewEncoder(&c.enc_buf)
+   c.framer = http2.NewFramer(conn, conn)
+   // the Framer only sets an emit func while it decodes HEADERS, and
+   // skip_headers can run before that
+   dec := hpack.NewDecoder(4096, func(hpack.HeaderField) {})
+   for _, set := range print.Settings {
+      switch set.ID {
+      case http2.SettingHeaderTableSize:
+         dec.SetAllowedMaxDynamicTableSize(set.Val)
+      case http2.SettingMaxHeaderListSize:
+         c.framer.MaxHeaderListSize = set.Val
+      }
+   }
+   c.framer.ReadMetaHeaders = dec
+   if _, err := io.WriteString(conn, http2.ClientPreface); err != nil {
+      return nil, err
+   }
+   if err := c.framer.WriteSettings(print.Settings...); err != nil {
+      return nil, err
+   }
+   if print.Window_Update >= 1 {
+      err := c.framer.WriteWindowUpdate(0, print.Window_Update)
+      if err != nil {
+         return nil, err
+      }
+   }
+   for _, pri := range print.Priorities {
+      err := c.framer.WritePriority(pri.Stream_ID, pri.PriorityParam)
+      if err != nil {
+         return nil, err
+      }
+      // like Mozilla Firefox, requests start after the priority streams
+      if pri.Stream_ID >= c.next_id {
+         c.next_id = pri.Stream_ID | 1 + 2
+      }
+   }
+   go c.read_loop()
+   return &c, nil
+}
+
+// false after GOAWAY or a connection error
+func (c *h2_conn) usable() bool {
+   c.mu.Lock()
+   defer c.mu.Unlock()
+   return c.err == nil && !c.goaway
+}
+
+func (c *h2_conn) close(err error) {
+   c.mu.Lock()
+   defer c.mu.Unlock()
+   if c.err == nil {
+      c.err = err
+   }
+   for _, s := range c.streams {
+      s.fail(err)
+   }
+   c.cond.Broadcast()
+   c.conn.Close()
+}
+
+// caller holds mu
+func (s *h2_stream) fail(err error) {
+   if s.err == nil && !s.done {
+      s.err = err
+   }
+   s.finish()
+}
+
+// caller holds mu
+func (s *h2_stream) end() {
+   s.done = true
+   s.finish()
+}
+
+// caller holds mu
+func (s *h2_stream) finish() {
+   delete(s.conn.streams, s.id)
+   close_once(s.finished)
+   close_once(s.ready)
+}
+
+// caller holds mu
+func (s *h2_stream) set_ready() {
+   close_once(s.ready)
+}
+
+func close_once(ch chan struct{}) {
+   select {
+   case <-ch:
+   default:
+      close(ch)
+   }
+}
+
+func (c *h2_conn) write(f func(*http2.Framer) error) error {
+   c.write_mu.Lock()
+   defer c.write_mu.Unlock()
+   return f(c.framer)
+}
+
+func (c *h2_conn) read_loop() {
+   for {
+      frame, err := c.framer.ReadFrame()
+      if err != nil {
+         c.close(err)
+         return
+      }
+      if err := c.read_frame(frame); err != nil {
+         c.close(err)
+         return
+      }
+   }
+}
+
+func (c *h2_conn) read_frame(frame http2.Frame) error {
+   switch f := frame.(type) {
+   case *http2.SettingsFrame:
+      if f.IsAck() {
+         return nil
+      }
+      c.mu.Lock()
+      err := f.ForeachSetting(c.apply)
+      c.cond.Broadcast()
+      c.mu.Unlock()
+      if err != nil {
+         return err
+      }
+      return c.write(func(fr *http2.Framer) error {
+         // the encoder is only used with write_mu held
+         f.ForeachSetting(func(set http2.Setting) error {
+            if set.ID == http2.SettingHeaderTableSize {
+               c.enc.SetMaxDynamicTableSizeLimit(set.Val)
+            }
+            return nil
+         })
+         return fr.WriteSettingsAck()
+      })
+   case *http2.PingFrame:
+      if f.IsAck() {
+         return nil
+      }
+      return c.write(func(fr *http2.Framer) error {
+         return fr.WritePing(true, f.Data)
+      })
+   case *http2.WindowUpdateFrame:
+      c.mu.Lock()
+      defer c.mu.Unlock()
+      if f.StreamID == 0 {
+         c.send_window += int32(f.Increment)
+      } else if s := c.streams[f.StreamID]; s != nil {
+         s.send_window += int32(f.Increment)
+      }
+      c.cond.Broadcast()
+   case *http2.MetaHeadersFrame:
+      c.mu.Lock()
+      defer c.mu.Unlock()
+      if s := c.streams[f.StreamID]; s != nil {
+         s.headers(f)
+         c.cond.Broadcast()
+      }
+   case *http2.DataFrame:
+      return c.data(f)
+   case *http2.RSTStreamFrame:
+      c.mu.Lock()
+      defer c.mu.Unlock()
+      if s := c.streams[f.StreamID]; s != nil {
+         s.fail(http2.StreamError{StreamID: f.StreamID, Code: f.ErrCode})
+         c.cond.Broadcast()
+      }
+   case *http2.GoAwayFrame:
+      c.mu.Lock()
+      defer c.mu.Unlock()
+      c.goaway = true
+      for id, s := range c.streams {
+         if id > f.LastStreamID {
+            s.fail(errors.New("GOAWAY " + f.ErrCode.String()))
+         }
+      }
+      c.cond.Broadcast()
+   case *http2.PushPromiseFrame:
+      // the header block still has to be decoded, to keep HPACK in sync
+      err := c.skip_headers(f.HeaderBlockFragment(), f.HeadersEnded())
+      if err != nil {
+         return err
+      }
+      return c.write(func(fr *http2.Framer) error {
+         return fr.WriteRSTStream(f.PromiseID, http2.ErrCodeRefusedStream)
+      })
+   case *http2.ContinuationFrame:
+      // only after PUSH_PROMISE, as the Framer joins them after HEADERS
+      return c.skip_headers(f.HeaderBlockFragment(), f.HeadersEnded())
+   }
+   return nil
+}
+
+func (c *h2_conn) skip_headers(block []byte, end bool) error {
+   dec := c.framer.ReadMetaHeaders
+   if _, err := dec.Write(block); err != nil {
+      return err
+   }
+   if end {
+      return dec.Close()
+   }
+   return nil
+}
+
+// caller holds mu
+func (c *h2_conn) apply(set http2.Setting) error {
+   switch set.ID {
+   case http2.SettingInitialWindowSize:
+      delta := int32(set.Val) - c.initial_window
+      for _, s := range c.streams {
+         s.send_window += delta
+      }
+      c.initial_window = int32(set.Val)
+   case http2.SettingMaxFrameSize:
+      c.max_frame = set.Val
+   case http2.SettingMaxConcurrentStreams:
+      c.max_streams = set.Val
+   }
+   return set.Valid()
+}
+
+// caller holds mu
+func (s *h2_stream) headers(f *http2.MetaHeadersFrame) {
+   if s.res != nil {
+      // trailers
+      for _, field := range f.RegularFields() {
+         s.res.Trailer.Add(field.Name, field.Value)
+      }
+   } else {
+      status, err := strconv.Atoi(f.PseudoValue("status"))
+      if err != nil {
+         s.fail(err)
+         return
+      }
+      // 100 Continue and such, the real response comes next
+      if status < 200 {
+         return
+      }
+      s.res = &http.Response{
+         Body: h2_body{s},
+         ContentLength: -1,
+         Header: make(http.Header),
+         Proto: "HTTP/2.0",
+         ProtoMajor: 2,
+         Status: strconv.Itoa(status) + " " + http.StatusText(status),
+         StatusCode: status,
+         Trailer: make(http.Header),
+      }
+      for _, field := range f.RegularFields() {
+         s.res.Header.Add(http.CanonicalHeaderKey(field.Name), field.Value)
+      }
+      length := s.res.Header.Get("Content-Length")
+      if n, err := strconv.ParseInt(length, 10, 64); err == nil {
+         s.res.ContentLength = n
+      }
+      s.set_ready()
+   }
+   if f.StreamEnded() {
+      s.end()
+   }
+}
+
+func (c *h2_conn) data(f *http2.DataFrame) error {
+   c.mu.Lock()
+   s := c.streams[f.StreamID]
+   data := f.Data()
+   // padding, or data for a stream that is gone, is credited back now.
+   // Everything else is credited back as the body is read.
+   credit := f.Header().Length
+   if s != nil && s.res != nil {
+      s.body.Write(data)
+      credit -= uint32(len(data))
+      if f.StreamEnded() {
+         s.end()
+      }
+      c.cond.Broadcast()
+   }
+   // the stream window as well, while the stream is open
+   open := s != nil && !s.done && s.err == nil
+   c.mu.Unlock()
+   if credit == 0 {
+      return nil
+   }
+   return c.write(func(fr *http2.Framer) error {
+      if open {
+         err := fr.WriteWindowUpdate(f.StreamID, credit)
+         if err != nil {
+            return err
+         }
+      }
+      return fr.WriteWindowUpdate(0, credit)
+   })
+}
+
+type h2_body struct {
+   s *h2_stream
+}
+
+func (b h2_body) Read(buf []byte) (int, error) {
+   c := b.s.conn
+   c.mu.Lock()
+   for b.s.body.Len() == 0 && !b.s.done && b.s.err == nil {
+      c.cond.Wait()
+   }
+   if b.s.body.Len() == 0 {
+      defer c.mu.Unlock()
+      if b.s.err != nil {
+         return 0, b.s.err
+      }
+      return 0, io.EOF
+   }
+   n, _ := b.s.body.Read(buf)
+   done := b.s.done
+   c.mu.Unlock()
+   return n, c.write(func(fr *http2.Framer) error {
+      if !done {
+         err := fr.WriteWindowUpdate(b.s.id, uint32(n))
+         if err != nil {
+            return err
+         }
+      }
+      return fr.WriteWindowUpdate(0, uint32(n))
+   })
+}
+
+// the stream is reset if the body was not read to the end
+func (b h2_body) Close() error {
+   c := b.s.conn
+   c.mu.Lock()
+   done := b.s.done || b.s.err != nil
+   b.s.fail(errors.New("body closed"))
+   c.cond.Broadcast()
+   c.mu.Unlock()
+   if done {
+      return nil
+   }
+   return c.write(func(fr *http2.Framer) error {
+      return fr.WriteRSTStream(b.s.id, http2.ErrCodeCancel)
+   })
+}
+
+// the request body is sent while waiting for the response, as the server
+// can answer first
+func (c *h2_conn) round_trip(req *http.Request) (*http.Response, error) {
+   body := req.Body != nil && req.Body != http.NoBody
+   s, err := c.open(req, !body)
+   if err != nil {
+      return nil, err
+   }
+   go func() {
+      select {
+      case <-req.Context().Done():
+         c.reset(s, req.Context().Err())
+      case <-s.finished:
+      }
+   }()
+   if body {
+      go func() {
+         if err := c.send_body(s, req.Body); err != nil {
+            c.reset(s, err)
+         }
+      }()
+   }
+   <-s.ready
+   c.mu.Lock()
+   defer c.mu.Unlock()
+   if s.res == nil {
+      return nil, s.err
+   }
+   s.res.Request = req
+   return s.res, nil
+}
+
+func (c *h2_conn) reset(s *h2_stream, err error) {
+   c.mu.Lock()
+   if s.done || s.err != nil {
+      c.mu.Unlock()
+      return
+   }
+   s.fail(err)
+   c.cond.Broadcast()
+   c.mu.Unlock()
+   c.write(func(fr *http2.Framer) error {
+      return fr.WriteRSTStream(s.id, http2.ErrCodeCancel)
+   })
+}
+
+// stream IDs must be sent in order, so the ID is assigned with write_mu
+// held
+func (c *h2_conn) open(req *http.Request, end_stream bool) (*h2_stream, error) {
+   c.mu.Lock()
+   for c.err == nil && !c.goaway &&
+   len(c.streams) + c.reserved >= int(c.max_streams) {
+      c.cond.Wait()
+   }
+   if c.err != nil {
+      defer c.mu.Unlock()
+      return nil, c.err
+   }
+   if c.goaway {
+      c.mu.Unlock()
+      return nil, errors.New("connection is going away")
+   }
+   c.reserved++
+   c.mu.Unlock()
+   c.write_mu.Lock()
+   defer c.write_mu.Unlock()
+   block := c.encode(req)
+   c.mu.Lock()
+   c.reserved--
+   s := &h2_stream{
+      conn: c,
+      finished: make(chan struct{}),
+      id: c.next_id,
+      ready: make(chan struct{}),
+      send_window: c.initial_window,
+   }
+   c.next_id += 2
+   c.streams[s.id] = s
+   max_frame := int(c.max_frame)
+   c.mu.Unlock()
+   first := block
+   if len(first) > max_frame {
+      first = first[:max_frame]
+   }
+   err := c.framer.WriteHeaders(http2.HeadersFrameParam{
+      BlockFragment: first,
+      EndHeaders: len(first) == len(block),
+      EndStream: end_stream,
+      Priority: c.print.Headers_Priority,
+      StreamID: s.id,
+   })
+   for block = block[len(first):]; err == nil && len(block) >= 1; {
+      next := block
+      if len(next) > max_frame {
+         next = next[:max_frame]
+      }
+      block = block[len(next):]
+      err = c.framer.WriteContinuation(s.id, len(block) == 0, next)
+   }
+   if err != nil {
+      return nil, err
+   }
+   return s, nil
+}
+
+// caller holds write_mu
+func (c *h2_conn) encode(req *http.Request) []byte {
+   c.enc_buf.Reset()
+   write := func(name, value string) {
+      c.enc.WriteField(hpack.HeaderField{Name: name, Value: value})
+   }
+   host := req.Host
+   if host == "" {
+      host = req.URL.Host
+   }
+   for _, pseudo := range c.print.Pseudo_Headers {
+      switch pseudo {
+      case "m":
+         write(":method", req.Method)
+      case "a":
+         write(":authority", host)
+      case "s":
+         write(":scheme", "https")
+      case "p":
+         write(":path", req.URL.RequestURI())
+      }
+   }
+   var keys []string
+   for key := range req.Header {
+      keys = append(keys, key)
+   }
+   sort.Strings(keys)
+   for _, key := range keys {
+      name := strings.ToLower(key)
+      switch name {
+      case "connection", "host", "keep-alive", "proxy-connection",
+      "transfer-encoding", "upgrade":
+         continue
+      }
+      for _, value := range req.Header[key] {
+         write(name, value)
+      }
+   }
+   if req.ContentLength >= 1 && req.Header.Get("Content-Length") == "" {
+      write("content-length", strconv.FormatInt(req.ContentLength, 10))
+   }
+   return append([]byte(nil), c.enc_buf.Bytes()...)
+}
+
+func (c *h2_conn) send_body(s *h2_stream, body io.ReadCloser) error {
+   defer body.Close()
+   buf := make([]byte, 16384)
+   for {
+      n, err := body.Read(buf)
+      data := buf[:n]
+      for len(data) >= 1 {
+         size, ok := c.take(s, len(data))
+         if !ok {
+            // the server is done with the stream, so stop sending
+            return nil
+         }
+         err := c.write(func(fr *http2.Framer) error {
+            return fr.WriteData(s.id, false, data[:size])
+         })
+         if err != nil {
+            return err
+         }
+         data = data[size:]
+      }
+      if err == io.EOF {
+         return c.write(func(fr *http2.Framer) error {
+            return fr.WriteData(s.id, true, nil)
+         })
+      }
+      if err != nil {
+         return err
+      }
+   }
+}
+
+// wait for flow control to allow up to n bytes
+func (c *h2_conn) take(s *h2_stream, n int) (int, bool) {
+   c.mu.Lock()
+   defer c.mu.Unlock()
+   for !s.done && s.err == nil && (s.send_window <= 0 || c.send_window <= 0) {
+      c.cond.Wait()
+   }
+   if s.done || s.err != nil {
+      return 0, false
+   }
+   for _, max := range []int32{s.send_window, c.send_window} {
+      if n > int(max) {
+         n = int(max)
+      }
+   }
+   if n > int(c.max_frame) {
+      n = int(c.max_frame)
+   }
+   s.send_window -= int32(n)
+   c.send_window -= int32(n)
+   return n, true
+}
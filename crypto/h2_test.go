@@ -0,0 +1,152 @@
+package crypto
+
+import (
+   "bytes"
+   "crypto/tls"
+   "golang.org/x/net/http2"
+   "golang.org/x/net/http2/hpack"
+   "io"
+   "net"
+   "testing"
+   "time"
+)
+
+// one connection of raw frames, for what http2.Server cannot do. handle gets
+// each HEADERS from the client, and the framer can be read from to wait for
+// WINDOW_UPDATE.
+func serve_h2(
+   t *testing.T, print string, settings []http2.Setting,
+   handle func(*http2.Framer, *http2.HeadersFrame) error,
+) (*Round_Tripper, string) {
+   server := new_echo_server(true)
+   t.Cleanup(server.Close)
+   ln, err := net.Listen("tcp", "127.0.0.1:0")
+   if err != nil {
+      t.Fatal(err)
+   }
+   t.Cleanup(func() {
+      ln.Close()
+   })
+   go func() {
+      conn, err := tls.NewListener(ln, server.TLS).Accept()
+      if err != nil {
+         return
+      }
+      defer conn.Close()
+      // a client that stalls fails the test, rather than hang it
+      conn.SetDeadline(time.Now().Add(9 * time.Second))
+      preface := make([]byte, len(http2.ClientPreface))
+      if _, err := io.ReadFull(conn, preface); err != nil {
+         t.Error(err)
+         return
+      }
+      fr := http2.NewFramer(conn, conn)
+      if err := fr.WriteSettings(settings...); err != nil {
+         t.Error(err)
+         return
+      }
+      for {
+         frame, err := fr.ReadFrame()
+         if err != nil {
+            return
+         }
+         if f, ok := frame.(*http2.HeadersFrame); ok {
+            if err := handle(fr, f); err != nil {
+               t.Error(err)
+               return
+            }
+         }
+      }
+   }()
+   r := new_round_tripper(t, server, "chrome_102")
+   r.H2, err = Parse_Akamai(print)
+   if err != nil {
+      t.Fatal(err)
+   }
+   return r, "https://" + ln.Addr().String()
+}
+
+func write_status(fr *http2.Framer, id uint32, end bool) error {
+   var buf bytes.Buffer
+   hpack.NewEncoder(&buf).WriteField(hpack.HeaderField{
+      Name: ":status", Value: "200",
+   })
+   return fr.WriteHeaders(http2.HeadersFrameParam{
+      BlockFragment: buf.Bytes(),
+      EndHeaders: true,
+      EndStream: end,
+      StreamID: id,
+   })
+}
+
+// each DATA has 1 byte of data, and 200 of padding. The stream window is 1000
+// bytes, so only the padding being credited back lets it finish.
+func Test_H2_Padding(t *testing.T) {
+   const frames = 20
+   r, ref := serve_h2(
+      t, "4:1000|0|0|m,a,s,p", nil,
+      func(fr *http2.Framer, f *http2.HeadersFrame) error {
+         if err := write_status(fr, f.StreamID, false); err != nil {
+            return err
+         }
+         window := 1000
+         for i := 0; i < frames; i++ {
+            for window < 201 {
+               frame, err := fr.ReadFrame()
+               if err != nil {
+                  return err
+               }
+               update, ok := frame.(*http2.WindowUpdateFrame)
+               if ok && update.StreamID == f.StreamID {
+                  window += int(update.Increment)
+               }
+            }
+            err := fr.WriteDataPadded(
+               f.StreamID, i == frames - 1, []byte{'a'}, make([]byte, 200),
+            )
+            if err != nil {
+               return err
+            }
+            window -= 201
+         }
+         return nil
+      },
+   )
+   res, err := r.RoundTrip(get(t, ref))
+   if err != nil {
+      t.Fatal(err)
+   }
+   defer res.Body.Close()
+   body, err := io.ReadAll(res.Body)
+   if err != nil {
+      t.Fatal(err)
+   }
+   if len(body) != frames {
+      t.Fatal(len(body))
+   }
+}
+
+// the server allows no dynamic table, so after the SETTINGS each header block
+// starts with a dynamic table size update to zero
+func Test_H2_Header_Table_Size(t *testing.T) {
+   blocks := make(chan []byte, 2)
+   r, ref := serve_h2(
+      t, Chrome_Akamai,
+      []http2.Setting{{ID: http2.SettingHeaderTableSize, Val: 0}},
+      func(fr *http2.Framer, f *http2.HeadersFrame) error {
+         blocks <- append([]byte{}, f.HeaderBlockFragment()...)
+         return write_status(fr, f.StreamID, true)
+      },
+   )
+   for i := 0; i < 2; i++ {
+      res, err := r.RoundTrip(get(t, ref))
+      if err != nil {
+         t.Fatal(err)
+      }
+      res.Body.Close()
+   }
+   <-blocks
+   if block := <-blocks; block[0] != 0x20 {
+      t.Fatalf("%x", block)
+   }
+}
@@ -0,0 +1,181 @@
+package crypto
+
+import (
+   "crypto/sha256"
+   "encoding/hex"
+   "github.com/refraction-networking/utls"
+   "sort"
+   "strings"
+)
+
+// what the server chose, from the ServerHello
+type Server_Hello struct {
+   ALPN string
+   Cipher_Suite uint16
+   // in the order sent
+   Extensions []uint16
+   // from the supported_versions extension, zero if not sent
+   Supported_Version uint16
+   // handshake version, locked at TLS 1.2 since TLS 1.3
+   Version uint16
+}
+
+// github.com/FoxIO-LLC/ja4/blob/main/technical_details/JA4S.md
+func Format_JA4S(hello *Server_Hello) string {
+   var b []byte
+   b = append(b, 't')
+   if hello.Supported_Version >= 1 {
+      b = append(b, ja4_version(hello.Supported_Version)...)
+   } else {
+      b = append(b, ja4_version(hello.Version)...)
+   }
+   b = append(b, two_digits(len(hello.Extensions))...)
+   b = append(b, ja4_alpn(hello.ALPN)...)
+   b = append(b, '_')
+   b = append(b, hex_uint16(hello.Cipher_Suite)...)
+   b = append(b, '_')
+   var exts []string
+   for _, ext := range hello.Extensions {
+      exts = append(exts, hex_uint16(ext))
+   }
+   b = append(b, ja4_hash(strings.Join(exts, ","))...)
+   return string(b)
+}
+
+// github.com/FoxIO-LLC/ja4/blob/main/technical_details/JA4.md
+func Format_JA4(spec *tls.ClientHelloSpec) (string, error) {
+   var (
+      alpn string
+      algs []string
+      count int
+      exts []string
+      sni byte = 'i'
+      vers = spec.TLSVersMax
+   )
+   for _, val := range spec.Extensions {
+      typ, err := extension_type(val)
+      if err != nil {
+         return "", err
+      }
+      if is_grease(typ) {
+         continue
+      }
+      count++
+      switch ext := val.(type) {
+      case *tls.ALPNExtension:
+         if len(ext.AlpnProtocols) >= 1 {
+            alpn = ext.AlpnProtocols[0]
+         }
+      case *tls.SignatureAlgorithmsExtension:
+         for _, alg := range ext.SupportedSignatureAlgorithms {
+            algs = append(algs, hex_uint16(uint16(alg)))
+         }
+      case *tls.SupportedVersionsExtension:
+         vers = 0
+         for _, ver := range ext.Versions {
+            if !is_grease(ver) && ver > vers {
+               vers = ver
+            }
+         }
+      }
+      // SNI and ALPN are counted, but not hashed
+      switch typ {
+      case 0:
+         sni = 'd'
+      case 16:
+      default:
+         exts = append(exts, hex_uint16(typ))
+      }
+   }
+   var suites []string
+   for _, suite := range spec.CipherSuites {
+      if !is_grease(suite) {
+         suites = append(suites, hex_uint16(suite))
+      }
+   }
+   var b []byte
+   b = append(b, 't')
+   b = append(b, ja4_version(vers)...)
+   b = append(b, sni)
+   b = append(b, two_digits(len(suites))...)
+   b = append(b, two_digits(count)...)
+   b = append(b, ja4_alpn(alpn)...)
+   b = append(b, '_')
+   sort.Strings(suites)
+   b = append(b, ja4_hash(strings.Join(suites, ","))...)
+   b = append(b, '_')
+   sort.Strings(exts)
+   ext := strings.Join(exts, ",")
+   if algs != nil {
+      ext += "_" + strings.Join(algs, ",")
+   }
+   b = append(b, ja4_hash(ext)...)
+   return string(b), nil
+}
+
+// first 12 characters of the SHA-256, or all zero if there is nothing to hash
+func ja4_hash(s string) string {
+   if s == "" {
+      return "000000000000"
+   }
+   sum := sha256.Sum256([]byte(s))
+   return hex.EncodeToString(sum[:6])
+}
+
+func hex_uint16(v uint16) string {
+   return hex.EncodeToString([]byte{byte(v >> 8), byte(v)})
+}
+
+// capped at 99
+func two_digits(n int) string {
+   if n > 99 {
+      n = 99
+   }
+   return string([]byte{'0' + byte(n / 10), '0' + byte(n % 10)})
+}
+
+func ja4_version(v uint16) string {
+   switch v {
+   case tls.VersionTLS13:
+      return "13"
+   case tls.VersionTLS12:
+      return "12"
+   case tls.VersionTLS11:
+      return "11"
+   case tls.VersionTLS10:
+      return "10"
+   case tls.VersionSSL30:
+      return "s3"
+   case 0x0002:
+      return "s2"
+   case 0xFEFF:
+      return "d1"
+   case 0xFEFD:
+      return "d2"
+   case 0xFEFC:
+      return "d3"
+   }
+   return "00"
+}
+
+// first and last character, or first and last hex digit if either is not
+// alphanumeric
+func ja4_alpn(s string) string {
+   if s == "" {
+      return "00"
+   }
+   first, last := s[0], s[len(s)-1]
+   if !is_alphanumeric(first) || !is_alphanumeric(last) {
+      h := hex.EncodeToString([]byte(s))
+      return h[:1] + h[len(h)-1:]
+   }
+   return string([]byte{first, last})
+}
+
+func is_alphanumeric(c byte) bool {
+   switch {
+   case '0' <= c && c <= '9', 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z':
+      return true
+   }
+   return false
+}
@@ -0,0 +1,69 @@
+package crypto
+
+import (
+   "github.com/refraction-networking/utls"
+   "testing"
+)
+
+// github.com/FoxIO-LLC/ja4/blob/main/technical_details/JA4.md
+func Test_JA4(t *testing.T) {
+   spec, err := Parse_JA3(
+      "771,2570-4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-" +
+      "49172-156-157-47-53,2570-0-23-65281-10-11-35-16-5-13-18-51-45-43-27-" +
+      "17513-6682-21,2570-29-23-24,0",
+   )
+   if err != nil {
+      t.Fatal(err)
+   }
+   for _, val := range spec.Extensions {
+      switch ext := val.(type) {
+      case *tls.ALPNExtension:
+         ext.AlpnProtocols = []string{"h2", "http/1.1"}
+      case *tls.SignatureAlgorithmsExtension:
+         ext.SupportedSignatureAlgorithms = []tls.SignatureScheme{
+            0x0403, 0x0804, 0x0401, 0x0503, 0x0805, 0x0501, 0x0806, 0x0601,
+         }
+      }
+   }
+   ja4, err := Format_JA4(spec)
+   if err != nil {
+      t.Fatal(err)
+   }
+   if ja4 != "t13d1516h2_8daaf6152771_e5627efa2ab1" {
+      t.Fatal(ja4)
+   }
+}
+
+// github.com/FoxIO-LLC/ja4/blob/main/technical_details/JA4S.md
+func Test_JA4S(t *testing.T) {
+   hello := Server_Hello{
+      Cipher_Suite: tls.TLS_AES_128_GCM_SHA256,
+      Extensions: []uint16{51, 43},
+      Supported_Version: tls.VersionTLS13,
+      Version: tls.VersionTLS12,
+   }
+   ja4 := Format_JA4S(&hello)
+   if ja4 != "t130200_1301_234ea6891581" {
+      t.Fatal(ja4)
+   }
+}
+
+func Test_JA4_Android(t *testing.T) {
+   tests := map[string]string{
+      Android_API_24: "t12d1808h1_",
+      Android_API_29: "t13d1713h1_",
+   }
+   for hello, prefix := range tests {
+      spec, err := Parse_JA3(hello)
+      if err != nil {
+         t.Fatal(err)
+      }
+      ja4, err := Format_JA4(spec)
+      if err != nil {
+         t.Fatal(err)
+      }
+      if ja4[:len(prefix)] != prefix {
+         t.Fatal(ja4)
+      }
+   }
+}
@@ -0,0 +1,539 @@
+package crypto
+
+import (
+   "errors"
+   "github.com/refraction-networking/utls"
+   "net/http"
+   "sort"
+)
+
+// each call returns a new spec, as uTLS writes to the spec during the
+// handshake
+type Preset func() *tls.ClientHelloSpec
+
+var presets = map[string]Preset{
+   "android_api_24": android_api_24,
+   "android_api_25": android_api_25,
+   "android_api_26": android_api_26,
+   "android_api_29": android_api_29,
+   "android_api_32": android_api_29,
+   "chrome_102": chrome_102,
+   "firefox_102": firefox_102,
+   "ios_14": ios_14,
+   "okhttp_android_11": okhttp_android_11,
+}
+
+// HTTP/2 fingerprint to go with the ClientHelloSpec, if the preset has one
+var h2_presets = map[string]string{
+   "chrome_102": Chrome_Akamai,
+   "firefox_102": Firefox_Akamai,
+}
+
+// sorted
+func Preset_Names() []string {
+   var names []string
+   for name := range presets {
+      names = append(names, name)
+   }
+   sort.Strings(names)
+   return names
+}
+
+func Get_Preset(name string) (Preset, error) {
+   preset, ok := presets[name]
+   if !ok {
+      return nil, errors.New("unknown preset " + name)
+   }
+   return preset, nil
+}
+
+// like Transport, but with a new spec for each connection. Transport is
+// HTTP/1.1 only, so presets that offer h2, such as chrome_102, are an error,
+// as most servers would select h2.
+func Preset_Transport(name string) (*http.Transport, error) {
+   preset, err := Get_Preset(name)
+   if err != nil {
+      return nil, err
+   }
+   if offers_h2(preset()) {
+      return nil, errors.New(
+         "preset " + name + " offers h2, use Preset_Round_Tripper",
+      )
+   }
+   return transport(preset, Proxy_From_Environment), nil
+}
+
+func offers_h2(spec *tls.ClientHelloSpec) bool {
+   for _, ext := range spec.Extensions {
+      if alpn, ok := ext.(*tls.ALPNExtension); ok {
+         for _, proto := range alpn.AlpnProtocols {
+            if proto == "h2" {
+               return true
+            }
+         }
+      }
+   }
+   return false
+}
+
+// like New_Round_Tripper, with H2 set to match the preset
+func Preset_Round_Tripper(name string) (*Round_Tripper, error) {
+   preset, err := Get_Preset(name)
+   if err != nil {
+      return nil, err
+   }
+   r := New_Round_Tripper(preset)
+   if print, ok := h2_presets[name]; ok {
+      r.H2, err = Parse_Akamai(print)
+      if err != nil {
+         return nil, err
+      }
+   }
+   return r, nil
+}
+
+// Android API 24, from android_handshake
+var android_signatures = []tls.SignatureScheme{
+   tls.PKCS1WithSHA512,
+   tls.ECDSAWithP521AndSHA512,
+   tls.PKCS1WithSHA384,
+   tls.ECDSAWithP384AndSHA384,
+   tls.PKCS1WithSHA256,
+   tls.ECDSAWithP256AndSHA256,
+   0x0301,
+   0x0303,
+   tls.PKCS1WithSHA1,
+   tls.ECDSAWithSHA1,
+}
+
+// BoringSSL default, Android API 26 and later
+var boring_signatures = []tls.SignatureScheme{
+   tls.ECDSAWithP256AndSHA256,
+   tls.PSSWithSHA256,
+   tls.PKCS1WithSHA256,
+   tls.ECDSAWithP384AndSHA384,
+   tls.PSSWithSHA384,
+   tls.PKCS1WithSHA384,
+   tls.PSSWithSHA512,
+   tls.PKCS1WithSHA512,
+   tls.PKCS1WithSHA1,
+}
+
+func android_api_24() *tls.ClientHelloSpec {
+   return &tls.ClientHelloSpec{
+      CipherSuites: []uint16{
+         tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+         tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+         tls.FAKE_TLS_DHE_RSA_WITH_AES_128_GCM_SHA256,
+         tls.FAKE_TLS_DHE_RSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+         tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+         tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+         tls.FAKE_TLS_DHE_RSA_WITH_AES_128_CBC_SHA,
+         tls.FAKE_TLS_DHE_RSA_WITH_AES_256_CBC_SHA,
+         tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+         tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+      },
+      CompressionMethods: []uint8{0},
+      Extensions: []tls.TLSExtension{
+         &tls.RenegotiationInfoExtension{},
+         &tls.SNIExtension{},
+         &tls.UtlsExtendedMasterSecretExtension{},
+         &tls.SessionTicketExtension{},
+         &tls.SignatureAlgorithmsExtension{
+            SupportedSignatureAlgorithms: android_signatures,
+         },
+         &tls.ALPNExtension{AlpnProtocols: []string{"http/1.1"}},
+         &tls.SupportedPointsExtension{SupportedPoints: []uint8{0}},
+         &tls.SupportedCurvesExtension{Curves: []tls.CurveID{tls.CurveP256}},
+      },
+      TLSVersMax: tls.VersionTLS12,
+      TLSVersMin: tls.VersionTLS10,
+   }
+}
+
+func android_api_25() *tls.ClientHelloSpec {
+   spec := android_api_24()
+   for _, ext := range spec.Extensions {
+      curves, ok := ext.(*tls.SupportedCurvesExtension)
+      if ok {
+         curves.Curves = []tls.CurveID{
+            tls.CurveP256, tls.CurveP384, tls.CurveP521,
+         }
+      }
+   }
+   return spec
+}
+
+func android_api_26() *tls.ClientHelloSpec {
+   return &tls.ClientHelloSpec{
+      CipherSuites: []uint16{
+         tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+         tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+         tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+         tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+         tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+         tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+      },
+      CompressionMethods: []uint8{0},
+      Extensions: []tls.TLSExtension{
+         &tls.RenegotiationInfoExtension{},
+         &tls.SNIExtension{},
+         &tls.UtlsExtendedMasterSecretExtension{},
+         &tls.SessionTicketExtension{},
+         &tls.SignatureAlgorithmsExtension{
+            SupportedSignatureAlgorithms: boring_signatures,
+         },
+         &tls.StatusRequestExtension{},
+         &tls.ALPNExtension{AlpnProtocols: []string{"http/1.1"}},
+         &tls.SupportedPointsExtension{SupportedPoints: []uint8{0}},
+         &tls.SupportedCurvesExtension{
+            Curves: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+         },
+      },
+      TLSVersMax: tls.VersionTLS12,
+      TLSVersMin: tls.VersionTLS10,
+   }
+}
+
+func android_api_29() *tls.ClientHelloSpec {
+   return &tls.ClientHelloSpec{
+      CipherSuites: []uint16{
+         tls.TLS_AES_128_GCM_SHA256,
+         tls.TLS_AES_256_GCM_SHA384,
+         tls.TLS_CHACHA20_POLY1305_SHA256,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+         tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+         tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+         tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+         tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+         tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+      },
+      CompressionMethods: []uint8{0},
+      Extensions: []tls.TLSExtension{
+         &tls.SNIExtension{},
+         &tls.UtlsExtendedMasterSecretExtension{},
+         &tls.RenegotiationInfoExtension{},
+         &tls.SupportedCurvesExtension{
+            Curves: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+         },
+         &tls.SupportedPointsExtension{SupportedPoints: []uint8{0}},
+         &tls.SessionTicketExtension{},
+         &tls.ALPNExtension{AlpnProtocols: []string{"http/1.1"}},
+         &tls.StatusRequestExtension{},
+         &tls.SignatureAlgorithmsExtension{
+            SupportedSignatureAlgorithms: boring_signatures,
+         },
+         &tls.KeyShareExtension{
+            KeyShares: []tls.KeyShare{{Group: tls.X25519}},
+         },
+         &tls.PSKKeyExchangeModesExtension{Modes: []uint8{tls.PskModeDHE}},
+         &tls.SupportedVersionsExtension{
+            Versions: []uint16{tls.VersionTLS13, tls.VersionTLS12},
+         },
+         &tls.UtlsPaddingExtension{GetPaddingLen: tls.BoringPaddingStyle},
+      },
+      TLSVersMax: tls.VersionTLS13,
+      TLSVersMin: tls.VersionTLS10,
+   }
+}
+
+func chrome_102() *tls.ClientHelloSpec {
+   return &tls.ClientHelloSpec{
+      CipherSuites: []uint16{
+         tls.GREASE_PLACEHOLDER,
+         tls.TLS_AES_128_GCM_SHA256,
+         tls.TLS_AES_256_GCM_SHA384,
+         tls.TLS_CHACHA20_POLY1305_SHA256,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+         tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+         tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+         tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+         tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+         tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+      },
+      CompressionMethods: []uint8{0},
+      Extensions: []tls.TLSExtension{
+         &tls.UtlsGREASEExtension{},
+         &tls.SNIExtension{},
+         &tls.UtlsExtendedMasterSecretExtension{},
+         &tls.RenegotiationInfoExtension{
+            Renegotiation: tls.RenegotiateOnceAsClient,
+         },
+         &tls.SupportedCurvesExtension{
+            Curves: []tls.CurveID{
+               tls.GREASE_PLACEHOLDER,
+               tls.X25519,
+               tls.CurveP256,
+               tls.CurveP384,
+            },
+         },
+         &tls.SupportedPointsExtension{SupportedPoints: []uint8{0}},
+         &tls.SessionTicketExtension{},
+         &tls.ALPNExtension{AlpnProtocols: []string{"h2", "http/1.1"}},
+         &tls.StatusRequestExtension{},
+         &tls.SignatureAlgorithmsExtension{
+            SupportedSignatureAlgorithms: boring_signatures[:8],
+         },
+         &tls.SCTExtension{},
+         &tls.KeyShareExtension{
+            KeyShares: []tls.KeyShare{
+               {Data: []byte{0}, Group: tls.GREASE_PLACEHOLDER},
+               {Group: tls.X25519},
+            },
+         },
+         &tls.PSKKeyExchangeModesExtension{Modes: []uint8{tls.PskModeDHE}},
+         &tls.SupportedVersionsExtension{
+            Versions: []uint16{
+               tls.GREASE_PLACEHOLDER, tls.VersionTLS13, tls.VersionTLS12,
+            },
+         },
+         &tls.UtlsCompressCertExtension{
+            Algorithms: []tls.CertCompressionAlgo{tls.CertCompressionBrotli},
+         },
+         &tls.ApplicationSettingsExtension{SupportedProtocols: []string{"h2"}},
+         &tls.UtlsGREASEExtension{},
+         &tls.UtlsPaddingExtension{GetPaddingLen: tls.BoringPaddingStyle},
+      },
+      TLSVersMax: tls.VersionTLS13,
+      TLSVersMin: tls.VersionTLS10,
+   }
+}
+
+func firefox_102() *tls.ClientHelloSpec {
+   return &tls.ClientHelloSpec{
+      CipherSuites: []uint16{
+         tls.TLS_AES_128_GCM_SHA256,
+         tls.TLS_CHACHA20_POLY1305_SHA256,
+         tls.TLS_AES_256_GCM_SHA384,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+         tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+         tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+         tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+         tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+         tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+      },
+      CompressionMethods: []uint8{0},
+      Extensions: []tls.TLSExtension{
+         &tls.SNIExtension{},
+         &tls.UtlsExtendedMasterSecretExtension{},
+         &tls.RenegotiationInfoExtension{
+            Renegotiation: tls.RenegotiateOnceAsClient,
+         },
+         &tls.SupportedCurvesExtension{
+            Curves: []tls.CurveID{
+               tls.X25519,
+               tls.CurveP256,
+               tls.CurveP384,
+               tls.CurveP521,
+               tls.CurveID(tls.FakeFFDHE2048),
+               tls.CurveID(tls.FakeFFDHE3072),
+            },
+         },
+         &tls.SupportedPointsExtension{SupportedPoints: []uint8{0}},
+         &tls.SessionTicketExtension{},
+         &tls.ALPNExtension{AlpnProtocols: []string{"h2", "http/1.1"}},
+         &tls.StatusRequestExtension{},
+         &tls.DelegatedCredentialsExtension{
+            AlgorithmsSignature: []tls.SignatureScheme{
+               tls.ECDSAWithP256AndSHA256,
+               tls.ECDSAWithP384AndSHA384,
+               tls.ECDSAWithP521AndSHA512,
+               tls.ECDSAWithSHA1,
+            },
+         },
+         &tls.KeyShareExtension{
+            KeyShares: []tls.KeyShare{
+               {Group: tls.X25519}, {Group: tls.CurveP256},
+            },
+         },
+         &tls.SupportedVersionsExtension{
+            Versions: []uint16{tls.VersionTLS13, tls.VersionTLS12},
+         },
+         &tls.SignatureAlgorithmsExtension{
+            SupportedSignatureAlgorithms: []tls.SignatureScheme{
+               tls.ECDSAWithP256AndSHA256,
+               tls.ECDSAWithP384AndSHA384,
+               tls.ECDSAWithP521AndSHA512,
+               tls.PSSWithSHA256,
+               tls.PSSWithSHA384,
+               tls.PSSWithSHA512,
+               tls.PKCS1WithSHA256,
+               tls.PKCS1WithSHA384,
+               tls.PKCS1WithSHA512,
+               tls.ECDSAWithSHA1,
+               tls.PKCS1WithSHA1,
+            },
+         },
+         &tls.PSKKeyExchangeModesExtension{Modes: []uint8{tls.PskModeDHE}},
+         &tls.FakeRecordSizeLimitExtension{Limit: 0x4001},
+         &tls.UtlsPaddingExtension{GetPaddingLen: tls.BoringPaddingStyle},
+      },
+      TLSVersMax: tls.VersionTLS13,
+      TLSVersMin: tls.VersionTLS10,
+   }
+}
+
+// Safari
+func ios_14() *tls.ClientHelloSpec {
+   return &tls.ClientHelloSpec{
+      CipherSuites: []uint16{
+         tls.GREASE_PLACEHOLDER,
+         tls.TLS_AES_128_GCM_SHA256,
+         tls.TLS_AES_256_GCM_SHA384,
+         tls.TLS_CHACHA20_POLY1305_SHA256,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+         tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+         tls.DISABLED_TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA384,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+         tls.DISABLED_TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA384,
+         tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
+         tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+         tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+         tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+         tls.DISABLED_TLS_RSA_WITH_AES_256_CBC_SHA256,
+         tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
+         tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+         tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+         0xC008,
+         tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,
+         tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+      },
+      CompressionMethods: []uint8{0},
+      Extensions: []tls.TLSExtension{
+         &tls.UtlsGREASEExtension{},
+         &tls.SNIExtension{},
+         &tls.UtlsExtendedMasterSecretExtension{},
+         &tls.RenegotiationInfoExtension{
+            Renegotiation: tls.RenegotiateOnceAsClient,
+         },
+         &tls.SupportedCurvesExtension{
+            Curves: []tls.CurveID{
+               tls.GREASE_PLACEHOLDER,
+               tls.X25519,
+               tls.CurveP256,
+               tls.CurveP384,
+               tls.CurveP521,
+            },
+         },
+         &tls.SupportedPointsExtension{SupportedPoints: []uint8{0}},
+         &tls.ALPNExtension{AlpnProtocols: []string{"h2", "http/1.1"}},
+         &tls.StatusRequestExtension{},
+         &tls.SignatureAlgorithmsExtension{
+            SupportedSignatureAlgorithms: []tls.SignatureScheme{
+               tls.ECDSAWithP256AndSHA256,
+               tls.PSSWithSHA256,
+               tls.PKCS1WithSHA256,
+               tls.ECDSAWithP384AndSHA384,
+               tls.ECDSAWithSHA1,
+               tls.PSSWithSHA384,
+               tls.PSSWithSHA384,
+               tls.PKCS1WithSHA384,
+               tls.PSSWithSHA512,
+               tls.PKCS1WithSHA512,
+               tls.PKCS1WithSHA1,
+            },
+         },
+         &tls.SCTExtension{},
+         &tls.KeyShareExtension{
+            KeyShares: []tls.KeyShare{
+               {Data: []byte{0}, Group: tls.GREASE_PLACEHOLDER},
+               {Group: tls.X25519},
+            },
+         },
+         &tls.PSKKeyExchangeModesExtension{Modes: []uint8{tls.PskModeDHE}},
+         &tls.SupportedVersionsExtension{
+            Versions: []uint16{
+               tls.GREASE_PLACEHOLDER,
+               tls.VersionTLS13,
+               tls.VersionTLS12,
+               tls.VersionTLS11,
+               tls.VersionTLS10,
+            },
+         },
+         &tls.UtlsGREASEExtension{},
+         &tls.UtlsPaddingExtension{GetPaddingLen: tls.BoringPaddingStyle},
+      },
+      TLSVersMax: tls.VersionTLS13,
+      TLSVersMin: tls.VersionTLS10,
+   }
+}
+
+func okhttp_android_11() *tls.ClientHelloSpec {
+   return &tls.ClientHelloSpec{
+      CipherSuites: []uint16{
+         tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+         tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+         tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+         tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+         tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+         tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+         tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+         tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+      },
+      CompressionMethods: []uint8{0},
+      Extensions: []tls.TLSExtension{
+         &tls.SNIExtension{},
+         &tls.UtlsExtendedMasterSecretExtension{},
+         &tls.RenegotiationInfoExtension{},
+         &tls.SupportedCurvesExtension{
+            Curves: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+         },
+         &tls.SupportedPointsExtension{SupportedPoints: []uint8{0}},
+         &tls.StatusRequestExtension{},
+         &tls.SignatureAlgorithmsExtension{
+            SupportedSignatureAlgorithms: boring_signatures,
+         },
+      },
+      TLSVersMax: tls.VersionTLS12,
+      TLSVersMin: tls.VersionTLS10,
+   }
+}
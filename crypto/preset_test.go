@@ -0,0 +1,86 @@
+package crypto
+
+import (
+   "io"
+   "log"
+   "net/http"
+   "net/http/httptest"
+   "testing"
+)
+
+func Test_Preset_JA3(t *testing.T) {
+   tests := map[string]string{
+      "android_api_24": Android_API_24,
+      "android_api_25": Android_API_25,
+      "android_api_26": Android_API_26,
+      "android_api_29": Android_API_29,
+      "android_api_32": Android_API_32,
+   }
+   for name, hello := range tests {
+      preset, err := Get_Preset(name)
+      if err != nil {
+         t.Fatal(err)
+      }
+      ja3, err := Format_JA3(preset())
+      if err != nil {
+         t.Fatal(err)
+      }
+      if ja3 != hello {
+         t.Fatal(name, ja3)
+      }
+   }
+   if _, err := Get_Preset("android_api_1"); err == nil {
+      t.Fatal("android_api_1")
+   }
+}
+
+func Test_Preset_Transport(t *testing.T) {
+   server := httptest.NewUnstartedServer(http.HandlerFunc(
+      func(w http.ResponseWriter, r *http.Request) {
+         io.WriteString(w, r.Proto)
+      },
+   ))
+   server.Config.ErrorLog = log.New(io.Discard, "", 0)
+   server.EnableHTTP2 = true
+   server.StartTLS()
+   defer server.Close()
+   client := server.Client().Transport.(*http.Transport)
+   for _, name := range Preset_Names() {
+      tr, err := Preset_Transport(name)
+      if offers_h2(presets[name]()) {
+         if err == nil {
+            t.Fatal(name, "no error")
+         }
+         r := new_round_tripper(t, server, name)
+         res, err := r.RoundTrip(get(t, server.URL))
+         if err != nil {
+            t.Fatal(name, err)
+         }
+         res.Body.Close()
+         if res.Proto != "HTTP/2.0" {
+            t.Fatal(name, res.Proto)
+         }
+         continue
+      }
+      if err != nil {
+         t.Fatal(err)
+      }
+      tr.TLSClientConfig = client.TLSClientConfig
+      // twice, as the spec must not be reused
+      for i := 0; i < 2; i++ {
+         req, err := http.NewRequest("GET", server.URL, nil)
+         if err != nil {
+            t.Fatal(err)
+         }
+         req.Close = true
+         res, err := tr.RoundTrip(req)
+         if err != nil {
+            t.Fatal(name, err)
+         }
+         res.Body.Close()
+         if res.StatusCode != http.StatusOK {
+            t.Fatal(name, res.Status)
+         }
+      }
+   }
+}
@@ -0,0 +1,131 @@
+package crypto
+
+import (
+   "bufio"
+   "context"
+   "encoding/base64"
+   "errors"
+   "golang.org/x/net/http/httpproxy"
+   "golang.org/x/net/proxy"
+   "net"
+   "net/http"
+   "net/url"
+   "time"
+)
+
+// for connecting to the proxy, and for the tunnel handshake after
+var proxy_timeout = 30 * time.Second
+
+// like http.ProxyFromEnvironment, but HTTP_PROXY is also used for https URLs
+// if HTTPS_PROXY is not set, and the environment is read on each call
+func Proxy_From_Environment(req *http.Request) (*url.URL, error) {
+   config := httpproxy.FromEnvironment()
+   if config.HTTPSProxy == "" {
+      config.HTTPSProxy = config.HTTPProxy
+   }
+   return config.ProxyFunc()(req.URL)
+}
+
+// http.Transport only calls DialTLS for HTTPS if there is no proxy, so Proxy
+// has to give nil for those, and dial_proxy does the tunnel instead
+func http_proxy(
+   proxy func(*http.Request) (*url.URL, error),
+) func(*http.Request) (*url.URL, error) {
+   return func(req *http.Request) (*url.URL, error) {
+      if req.URL.Scheme == "https" {
+         return nil, nil
+      }
+      return proxy(req)
+   }
+}
+
+// connection to ref through the proxy for https://ref, if any. The uTLS
+// handshake is done after, so the proxy only sees the tunnel.
+func dial_proxy(
+   proxy func(*http.Request) (*url.URL, error), network, ref string,
+) (net.Conn, error) {
+   req := http.Request{
+      URL: &url.URL{Scheme: "https", Host: ref},
+      Header: make(http.Header),
+   }
+   addr, err := proxy(&req)
+   if err != nil {
+      return nil, err
+   }
+   if addr == nil {
+      return net.Dial(network, ref)
+   }
+   switch addr.Scheme {
+   case "http":
+      return dial_connect(addr, network, ref)
+   case "socks5", "socks5h":
+      return dial_socks5(addr, network, ref)
+   }
+   return nil, errors.New("unsupported proxy scheme " + addr.Scheme)
+}
+
+func dial_connect(addr *url.URL, network, ref string) (net.Conn, error) {
+   conn, err := net.DialTimeout(network, proxy_host(addr, "80"), proxy_timeout)
+   if err != nil {
+      return nil, err
+   }
+   // a stalled proxy would otherwise hang the dial
+   conn.SetDeadline(time.Now().Add(proxy_timeout))
+   req := http.Request{
+      Header: make(http.Header),
+      Host: ref,
+      Method: "CONNECT",
+      URL: &url.URL{Opaque: ref},
+   }
+   if addr.User != nil {
+      pass, _ := addr.User.Password()
+      auth := addr.User.Username() + ":" + pass
+      req.Header.Set(
+         "Proxy-Authorization",
+         "Basic " + base64.StdEncoding.EncodeToString([]byte(auth)),
+      )
+   }
+   if err := req.Write(conn); err != nil {
+      conn.Close()
+      return nil, err
+   }
+   // the proxy sends nothing else until the ClientHello, so nothing is left
+   // in the buffer
+   res, err := http.ReadResponse(bufio.NewReader(conn), &req)
+   if err != nil {
+      conn.Close()
+      return nil, err
+   }
+   res.Body.Close()
+   if res.StatusCode != http.StatusOK {
+      conn.Close()
+      return nil, errors.New("proxy CONNECT " + res.Status)
+   }
+   conn.SetDeadline(time.Time{})
+   return conn, nil
+}
+
+func dial_socks5(addr *url.URL, network, ref string) (net.Conn, error) {
+   var auth *proxy.Auth
+   if addr.User != nil {
+      auth = &proxy.Auth{User: addr.User.Username()}
+      auth.Password, _ = addr.User.Password()
+   }
+   dialer, err := proxy.SOCKS5(
+      network, proxy_host(addr, "1080"), auth, proxy.Direct,
+   )
+   if err != nil {
+      return nil, err
+   }
+   // the deadline covers the SOCKS5 handshake as well
+   ctx, cancel := context.WithTimeout(context.Background(), proxy_timeout)
+   defer cancel()
+   return dialer.(proxy.ContextDialer).DialContext(ctx, network, ref)
+}
+
+func proxy_host(addr *url.URL, port string) string {
+   if addr.Port() != "" {
+      port = addr.Port()
+   }
+   return net.JoinHostPort(addr.Hostname(), port)
+}
@@ -0,0 +1,309 @@
+package crypto
+
+import (
+   "encoding/base64"
+   "encoding/binary"
+   "errors"
+   "io"
+   "net"
+   "net/http"
+   "net/http/httptest"
+   "net/url"
+   "strconv"
+   "sync/atomic"
+   "testing"
+   "time"
+)
+
+func pipe(a, b net.Conn) {
+   go func() {
+      io.Copy(a, b)
+      a.Close()
+   }()
+   io.Copy(b, a)
+   b.Close()
+}
+
+// HTTP CONNECT only, with Basic auth
+func new_connect_proxy(user, pass string, tunnels *int32) *httptest.Server {
+   auth := base64.StdEncoding.EncodeToString([]byte(user + ":" + pass))
+   return httptest.NewServer(http.HandlerFunc(
+      func(w http.ResponseWriter, r *http.Request) {
+         if r.Method != "CONNECT" {
+            w.WriteHeader(http.StatusMethodNotAllowed)
+            return
+         }
+         if r.Header.Get("Proxy-Authorization") != "Basic " + auth {
+            w.WriteHeader(http.StatusProxyAuthRequired)
+            return
+         }
+         target, err := net.Dial("tcp", r.Host)
+         if err != nil {
+            w.WriteHeader(http.StatusBadGateway)
+            return
+         }
+         conn, _, err := w.(http.Hijacker).Hijack()
+         if err != nil {
+            target.Close()
+            return
+         }
+         atomic.AddInt32(tunnels, 1)
+         io.WriteString(conn, "HTTP/1.1 200 OK\r\n\r\n")
+         pipe(conn, target)
+      },
+   ))
+}
+
+// RFC 1928 CONNECT, with RFC 1929 username and password
+func new_socks5_proxy(user, pass string, tunnels *int32) (net.Listener, error) {
+   ln, err := net.Listen("tcp", "127.0.0.1:0")
+   if err != nil {
+      return nil, err
+   }
+   go func() {
+      for {
+         conn, err := ln.Accept()
+         if err != nil {
+            return
+         }
+         go func() {
+            target, err := socks5_accept(conn, user, pass)
+            if err != nil {
+               conn.Close()
+               return
+            }
+            atomic.AddInt32(tunnels, 1)
+            pipe(conn, target)
+         }()
+      }
+   }()
+   return ln, nil
+}
+
+func socks5_accept(conn net.Conn, user, pass string) (net.Conn, error) {
+   read := func(n int) ([]byte, error) {
+      buf := make([]byte, n)
+      _, err := io.ReadFull(conn, buf)
+      return buf, err
+   }
+   head, err := read(2)
+   if err != nil {
+      return nil, err
+   }
+   if _, err := read(int(head[1])); err != nil {
+      return nil, err
+   }
+   // username and password is the only method offered
+   conn.Write([]byte{5, 2})
+   head, err = read(2)
+   if err != nil {
+      return nil, err
+   }
+   name, err := read(int(head[1]))
+   if err != nil {
+      return nil, err
+   }
+   size, err := read(1)
+   if err != nil {
+      return nil, err
+   }
+   word, err := read(int(size[0]))
+   if err != nil {
+      return nil, err
+   }
+   if string(name) != user || string(word) != pass {
+      conn.Write([]byte{1, 1})
+      return nil, errors.New("invalid SOCKS5 password")
+   }
+   conn.Write([]byte{1, 0})
+   head, err = read(4)
+   if err != nil {
+      return nil, err
+   }
+   var host string
+   switch head[3] {
+   case 1:
+      ip, err := read(4)
+      if err != nil {
+         return nil, err
+      }
+      host = net.IP(ip).String()
+   case 3:
+      size, err := read(1)
+      if err != nil {
+         return nil, err
+      }
+      name, err := read(int(size[0]))
+      if err != nil {
+         return nil, err
+      }
+      host = string(name)
+   default:
+      return nil, errors.New("unsupported SOCKS5 address type")
+   }
+   port, err := read(2)
+   if err != nil {
+      return nil, err
+   }
+   target, err := net.Dial("tcp", net.JoinHostPort(
+      host, strconv.Itoa(int(binary.BigEndian.Uint16(port))),
+   ))
+   if err != nil {
+      conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+      return nil, err
+   }
+   conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+   return target, nil
+}
+
+func new_proxies(t *testing.T, tunnels *int32) []*url.URL {
+   connect := new_connect_proxy("user", "pass", tunnels)
+   t.Cleanup(connect.Close)
+   socks, err := new_socks5_proxy("user", "pass", tunnels)
+   if err != nil {
+      t.Fatal(err)
+   }
+   t.Cleanup(func() {
+      socks.Close()
+   })
+   return []*url.URL{
+      {Scheme: "http", User: url.UserPassword("user", "pass"),
+         Host: connect.Listener.Addr().String()},
+      {Scheme: "socks5", User: url.UserPassword("user", "pass"),
+         Host: socks.Addr().String()},
+   }
+}
+
+func Test_Proxy_Transport(t *testing.T) {
+   var tunnels int32
+   server := new_echo_server(false)
+   defer server.Close()
+   preset, err := Get_Preset("chrome_102")
+   if err != nil {
+      t.Fatal(err)
+   }
+   for _, proxy := range new_proxies(t, &tunnels) {
+      before := atomic.LoadInt32(&tunnels)
+      tr := Proxy_Transport(preset(), http.ProxyURL(proxy))
+      client := server.Client().Transport.(*http.Transport)
+      tr.TLSClientConfig = client.TLSClientConfig
+      res, err := tr.RoundTrip(get(t, server.URL))
+      if err != nil {
+         t.Fatal(proxy, err)
+      }
+      res.Body.Close()
+      if res.Header.Get("Proto") != "HTTP/1.1" {
+         t.Fatal(proxy, res.Header)
+      }
+      if atomic.LoadInt32(&tunnels) != before + 1 {
+         t.Fatal(proxy, "no tunnel")
+      }
+      // wrong password
+      proxy.User = url.UserPassword("user", "bad")
+      tr = Proxy_Transport(preset(), http.ProxyURL(proxy))
+      tr.TLSClientConfig = client.TLSClientConfig
+      if _, err := tr.RoundTrip(get(t, server.URL)); err == nil {
+         t.Fatal(proxy, "no error")
+      }
+   }
+}
+
+func Test_Proxy_Round_Tripper(t *testing.T) {
+   var tunnels int32
+   server := new_echo_server(true)
+   defer server.Close()
+   for _, proxy := range new_proxies(t, &tunnels) {
+      before := atomic.LoadInt32(&tunnels)
+      r := new_round_tripper(t, server, "chrome_102")
+      r.Proxy = http.ProxyURL(proxy)
+      for i := 0; i < 2; i++ {
+         res, err := r.RoundTrip(get(t, server.URL))
+         if err != nil {
+            t.Fatal(proxy, err)
+         }
+         res.Body.Close()
+         if res.Proto != "HTTP/2.0" {
+            t.Fatal(proxy, res.Proto)
+         }
+      }
+      if atomic.LoadInt32(&tunnels) != before + 1 {
+         t.Fatal(proxy, "tunnels", atomic.LoadInt32(&tunnels) - before)
+      }
+   }
+}
+
+func get(t *testing.T, ref string) *http.Request {
+   req, err := http.NewRequest("GET", ref, nil)
+   if err != nil {
+      t.Fatal(err)
+   }
+   return req
+}
+
+func Test_Proxy_From_Environment(t *testing.T) {
+   for _, key := range []string{"http_proxy", "https_proxy", "no_proxy"} {
+      t.Setenv(key, "")
+   }
+   t.Setenv("HTTP_PROXY", "http://proxy.test:8080")
+   t.Setenv("HTTPS_PROXY", "")
+   t.Setenv("NO_PROXY", "example.org")
+   tests := []struct {
+      ref string
+      proxy string
+   }{
+      {"https://example.com", "http://proxy.test:8080"},
+      {"http://example.com", "http://proxy.test:8080"},
+      {"https://example.org", ""},
+   }
+   for _, test := range tests {
+      proxy, err := Proxy_From_Environment(get(t, test.ref))
+      if err != nil {
+         t.Fatal(err)
+      }
+      if proxy == nil {
+         if test.proxy != "" {
+            t.Fatal(test)
+         }
+      } else if proxy.String() != test.proxy {
+         t.Fatal(test, proxy)
+      }
+   }
+   t.Setenv("HTTPS_PROXY", "socks5://proxy.test:1080")
+   proxy, err := Proxy_From_Environment(get(t, "https://example.com"))
+   if err != nil {
+      t.Fatal(err)
+   }
+   if proxy.String() != "socks5://proxy.test:1080" {
+      t.Fatal(proxy)
+   }
+}
+
+// the proxy accepts, then never answers
+func Test_Proxy_Timeout(t *testing.T) {
+   ln, err := net.Listen("tcp", "127.0.0.1:0")
+   if err != nil {
+      t.Fatal(err)
+   }
+   defer ln.Close()
+   go func() {
+      for {
+         conn, err := ln.Accept()
+         if err != nil {
+            return
+         }
+         defer conn.Close()
+      }
+   }()
+   timeout := proxy_timeout
+   proxy_timeout = 100 * time.Millisecond
+   defer func() {
+      proxy_timeout = timeout
+   }()
+   for _, scheme := range []string{"http", "socks5"} {
+      proxy := &url.URL{Scheme: scheme, Host: ln.Addr().String()}
+      _, err := dial_proxy(http.ProxyURL(proxy), "tcp", "example.com:443")
+      if err == nil {
+         t.Fatal(scheme, "no error")
+      }
+   }
+}
@@ -0,0 +1,196 @@
+package crypto
+
+import (
+   "errors"
+   "net"
+   "net/http"
+   "net/url"
+   "sync"
+)
+
+// HTTP/2 if the server selects h2 with ALPN, otherwise HTTP/1.1. Use
+// New_Round_Tripper, as the zero value is not usable.
+type Round_Tripper struct {
+   // used for HTTP/1.1, and for http URLs. RootCAs and InsecureSkipVerify
+   // from its TLSClientConfig apply to HTTP/2 as well.
+   H1 *http.Transport
+   // frames sent on each new HTTP/2 connection, see Parse_Akamai. A
+   // default is used if nil.
+   H2 *H2_Fingerprint
+   // used for every URL, with HTTPS tunneled before the uTLS handshake. It
+   // is Proxy_From_Environment to start with, and nil means no proxy. Do
+   // not set H1.Proxy.
+   Proxy func(*http.Request) (*url.URL, error)
+   // dials in progress, so concurrent requests share one
+   dials map[string]*h2_dial
+   // addresses where the server did not select h2
+   h1_addrs map[string]bool
+   h2_conns map[string]*h2_conn
+   mu sync.Mutex
+   // connections already dialed for HTTP/1.1, waiting for H1 to use them
+   pending map[string][]net.Conn
+   preset Preset
+}
+
+func New_Round_Tripper(preset Preset) *Round_Tripper {
+   r := Round_Tripper{
+      dials: make(map[string]*h2_dial),
+      h1_addrs: make(map[string]bool),
+      h2_conns: make(map[string]*h2_conn),
+      pending: make(map[string][]net.Conn),
+      preset: preset,
+   }
+   r.H1 = new(http.Transport)
+   r.H1.Proxy = http_proxy(r.proxy)
+   r.Proxy = Proxy_From_Environment
+   //lint:ignore SA1019 godocs.io/context
+   r.H1.DialTLS = func(network, ref string) (net.Conn, error) {
+      if conn := r.pop(ref); conn != nil {
+         return conn, nil
+      }
+      uconn, err := dial_tls(r.H1, r.preset, r.proxy, network, ref)
+      if err != nil {
+         return nil, err
+      }
+      if uconn.ConnectionState().NegotiatedProtocol == "h2" {
+         uconn.Close()
+         return nil, errors.New("server selected h2 for HTTP/1.1")
+      }
+      return uconn, nil
+   }
+   return &r
+}
+
+func (r *Round_Tripper) RoundTrip(req *http.Request) (*http.Response, error) {
+   if req.URL.Scheme != "https" {
+      return r.H1.RoundTrip(req)
+   }
+   addr := https_addr(req)
+   conn, pending, err := r.h2(addr)
+   if err != nil {
+      if req.Body != nil {
+         req.Body.Close()
+      }
+      return nil, err
+   }
+   if conn == nil {
+      res, err := r.H1.RoundTrip(req)
+      // H1 might have used an idle connection instead
+      if pending != nil {
+         r.drop(addr, pending)
+      }
+      return res, err
+   }
+   return conn.round_trip(req)
+}
+
+func (r *Round_Tripper) CloseIdleConnections() {
+   r.mu.Lock()
+   for addr, conn := range r.h2_conns {
+      conn.close(errors.New("connection closed"))
+      delete(r.h2_conns, addr)
+   }
+   for addr, conns := range r.pending {
+      for _, conn := range conns {
+         conn.Close()
+      }
+      delete(r.pending, addr)
+   }
+   r.mu.Unlock()
+   r.H1.CloseIdleConnections()
+}
+
+type h2_dial struct {
+   conn *h2_conn
+   done chan struct{}
+   err error
+}
+
+// HTTP/2 connection for the address, or nil if the server selected
+// HTTP/1.1. In that case the dialed connection is kept for H1, and returned
+// as pending.
+func (r *Round_Tripper) h2(addr string) (*h2_conn, net.Conn, error) {
+   r.mu.Lock()
+   if r.h1_addrs[addr] {
+      r.mu.Unlock()
+      return nil, nil, nil
+   }
+   if conn := r.h2_conns[addr]; conn != nil && conn.usable() {
+      r.mu.Unlock()
+      return conn, nil, nil
+   }
+   if dial := r.dials[addr]; dial != nil {
+      r.mu.Unlock()
+      <-dial.done
+      return dial.conn, nil, dial.err
+   }
+   dial := h2_dial{done: make(chan struct{})}
+   r.dials[addr] = &dial
+   r.mu.Unlock()
+   defer close(dial.done)
+   uconn, err := dial_tls(r.H1, r.preset, r.proxy, "tcp", addr)
+   r.mu.Lock()
+   defer r.mu.Unlock()
+   delete(r.dials, addr)
+   if err != nil {
+      dial.err = err
+      return nil, nil, err
+   }
+   if uconn.ConnectionState().NegotiatedProtocol != "h2" {
+      r.h1_addrs[addr] = true
+      r.pending[addr] = append(r.pending[addr], uconn)
+      return nil, uconn, nil
+   }
+   print := r.H2
+   if print == nil {
+      print = &default_h2
+   }
+   dial.conn, dial.err = new_h2_conn(uconn, print)
+   if dial.err != nil {
+      uconn.Close()
+      return nil, nil, dial.err
+   }
+   r.h2_conns[addr] = dial.conn
+   return dial.conn, nil, nil
+}
+
+func (r *Round_Tripper) proxy(req *http.Request) (*url.URL, error) {
+   if r.Proxy == nil {
+      return nil, nil
+   }
+   return r.Proxy(req)
+}
+
+// close the connection if H1 never took it
+func (r *Round_Tripper) drop(addr string, conn net.Conn) {
+   r.mu.Lock()
+   defer r.mu.Unlock()
+   conns := r.pending[addr]
+   for i, pending := range conns {
+      if pending == conn {
+         r.pending[addr] = append(conns[:i:i], conns[i+1:]...)
+         conn.Close()
+         return
+      }
+   }
+}
+
+func (r *Round_Tripper) pop(addr string) net.Conn {
+   r.mu.Lock()
+   defer r.mu.Unlock()
+   conns := r.pending[addr]
+   if len(conns) == 0 {
+      return nil
+   }
+   r.pending[addr] = conns[1:]
+   return conns[0]
+}
+
+// same form as the address given to http.Transport.DialTLS
+func https_addr(req *http.Request) string {
+   port := req.URL.Port()
+   if port == "" {
+      port = "443"
+   }
+   return net.JoinHostPort(req.URL.Hostname(), port)
+}
@@ -0,0 +1,185 @@
+package crypto
+
+import (
+   "bytes"
+   "io"
+   "log"
+   "net"
+   "net/http"
+   "net/http/httptest"
+   "strings"
+   "sync"
+   "sync/atomic"
+   "testing"
+)
+
+func new_echo_server(h2 bool) *httptest.Server {
+   return new_counting_server(h2, new(int32))
+}
+
+// conns is the number of connections accepted
+func new_counting_server(h2 bool, conns *int32) *httptest.Server {
+   server := httptest.NewUnstartedServer(http.HandlerFunc(
+      func(w http.ResponseWriter, r *http.Request) {
+         w.Header().Set("Proto", r.Proto)
+         if r.URL.Path == "/large" {
+            w.Write(bytes.Repeat([]byte{'a'}, 9 << 20))
+            return
+         }
+         io.Copy(w, r.Body)
+      },
+   ))
+   server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+      if state == http.StateNew {
+         atomic.AddInt32(conns, 1)
+      }
+   }
+   server.Config.ErrorLog = log.New(io.Discard, "", 0)
+   server.EnableHTTP2 = h2
+   server.StartTLS()
+   return server
+}
+
+func new_round_tripper(
+   t *testing.T, server *httptest.Server, name string,
+) *Round_Tripper {
+   r, err := Preset_Round_Tripper(name)
+   if err != nil {
+      t.Fatal(err)
+   }
+   client := server.Client().Transport.(*http.Transport)
+   r.H1.TLSClientConfig = client.TLSClientConfig
+   return r
+}
+
+func Test_Round_Tripper(t *testing.T) {
+   tests := []struct {
+      h2 bool
+      preset string
+      proto string
+   }{
+      {true, "chrome_102", "HTTP/2.0"},
+      {true, "firefox_102", "HTTP/2.0"},
+      {true, "android_api_29", "HTTP/1.1"},
+      {false, "chrome_102", "HTTP/1.1"},
+   }
+   for _, test := range tests {
+      server := new_echo_server(test.h2)
+      r := new_round_tripper(t, server, test.preset)
+      for i := 0; i < 2; i++ {
+         req, err := http.NewRequest(
+            "POST", server.URL, strings.NewReader("hello world"),
+         )
+         if err != nil {
+            t.Fatal(err)
+         }
+         res, err := r.RoundTrip(req)
+         if err != nil {
+            t.Fatal(test, err)
+         }
+         body, err := io.ReadAll(res.Body)
+         if err != nil {
+            t.Fatal(err)
+         }
+         res.Body.Close()
+         if res.Proto != test.proto || res.Header.Get("Proto") != test.proto {
+            t.Fatal(test, res.Proto, res.Header)
+         }
+         if string(body) != "hello world" {
+            t.Fatal(test, string(body))
+         }
+      }
+      r.CloseIdleConnections()
+      server.Close()
+   }
+}
+
+// more than the flow control windows, both ways, on concurrent streams
+func Test_Round_Tripper_Large(t *testing.T) {
+   server := new_echo_server(true)
+   defer server.Close()
+   r := new_round_tripper(t, server, "chrome_102")
+   defer r.CloseIdleConnections()
+   upload := bytes.Repeat([]byte{'b'}, 5 << 20)
+   var group sync.WaitGroup
+   for i := 0; i < 4; i++ {
+      group.Add(1)
+      go func(i int) {
+         defer group.Done()
+         req, err := http.NewRequest("GET", server.URL + "/large", nil)
+         size := 9 << 20
+         if i % 2 == 1 {
+            req, err = http.NewRequest(
+               "PUT", server.URL, bytes.NewReader(upload),
+            )
+            size = len(upload)
+         }
+         if err != nil {
+            t.Error(err)
+            return
+         }
+         res, err := r.RoundTrip(req)
+         if err != nil {
+            t.Error(err)
+            return
+         }
+         defer res.Body.Close()
+         body, err := io.ReadAll(res.Body)
+         if err != nil {
+            t.Error(err)
+            return
+         }
+         if len(body) != size || res.ProtoMajor != 2 {
+            t.Error(len(body), res.Proto)
+         }
+      }(i)
+   }
+   group.Wait()
+}
+
+// concurrent first requests share one dial, and a connection dialed for
+// HTTP/1.1 is closed if H1 does not use it
+func Test_Round_Tripper_Dial(t *testing.T) {
+   var conns int32
+   server := new_counting_server(true, &conns)
+   defer server.Close()
+   r := new_round_tripper(t, server, "chrome_102")
+   defer r.CloseIdleConnections()
+   var group sync.WaitGroup
+   for i := 0; i < 8; i++ {
+      group.Add(1)
+      go func() {
+         defer group.Done()
+         res, err := r.RoundTrip(get(t, server.URL))
+         if err != nil {
+            t.Error(err)
+            return
+         }
+         res.Body.Close()
+      }()
+   }
+   group.Wait()
+   if n := atomic.LoadInt32(&conns); n != 1 {
+      t.Fatal("h2 conns", n)
+   }
+   server = new_echo_server(false)
+   defer server.Close()
+   r = new_round_tripper(t, server, "chrome_102")
+   defer r.CloseIdleConnections()
+   addr := server.Listener.Addr().String()
+   for i := 0; i < 2; i++ {
+      res, err := r.RoundTrip(get(t, server.URL))
+      if err != nil {
+         t.Fatal(err)
+      }
+      io.Copy(io.Discard, res.Body)
+      res.Body.Close()
+      // dial again, while H1 has an idle connection
+      r.mu.Lock()
+      delete(r.h1_addrs, addr)
+      r.mu.Unlock()
+   }
+   if n := len(r.pending[addr]); n != 0 {
+      t.Fatal("pending", n)
+   }
+}
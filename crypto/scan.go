@@ -1,6 +1,7 @@
 package crypto
 
 import (
+   "crypto/rand"
    "github.com/refraction-networking/utls"
    "strconv"
    "strings"
@@ -69,18 +70,19 @@ func Parse_JA3(str string) (*tls.ClientHelloSpec, error) {
          }
       case "13":
          ext = &tls.SignatureAlgorithmsExtension{
-            SupportedSignatureAlgorithms: []tls.SignatureScheme{
-               // Android API 24
-               tls.ECDSAWithP256AndSHA256,
-               // httpbin.org
-               tls.PKCS1WithSHA256,
-            },
+            SupportedSignatureAlgorithms: signature_algorithms,
          }
       case "16":
          // Android API 24
          ext = &tls.ALPNExtension{
             AlpnProtocols: []string{"http/1.1"},
          }
+      case "18":
+         // Google Chrome
+         ext = &tls.SCTExtension{}
+      case "21":
+         // Google Chrome
+         ext = &tls.UtlsPaddingExtension{GetPaddingLen: tls.BoringPaddingStyle}
       case "23":
          // Android API 24
          ext = &tls.UtlsExtendedMasterSecretExtension{}
@@ -89,16 +91,60 @@ func Parse_JA3(str string) (*tls.ClientHelloSpec, error) {
          ext = &tls.UtlsCompressCertExtension{
             Algorithms: []tls.CertCompressionAlgo{tls.CertCompressionBrotli},
          }
+      case "28":
+         // Mozilla Firefox
+         ext = &tls.FakeRecordSizeLimitExtension{Limit: 0x4001}
+      case "34":
+         // Mozilla Firefox
+         ext = &tls.DelegatedCredentialsExtension{
+            AlgorithmsSignature: []tls.SignatureScheme{
+               tls.ECDSAWithP256AndSHA256,
+               tls.ECDSAWithP384AndSHA384,
+               tls.ECDSAWithP521AndSHA512,
+               tls.ECDSAWithSHA1,
+            },
+         }
+      case "35":
+         // Android API 24
+         ext = &tls.SessionTicketExtension{}
       case "43":
          // Android API 29
-         ext = &tls.SupportedVersionsExtension{
-            Versions: []uint16{tls.VersionTLS12},
+         vers := supported_versions(spec.CipherSuites)
+         ext = &tls.SupportedVersionsExtension{Versions: vers}
+         // the JA3 version is locked at TLS 1.2, so take the real maximum
+         // from here. Format_JA3 locks it again.
+         if len(vers) >= 2 && vers[len(vers)-2] == tls.VersionTLS13 {
+            spec.TLSVersMax = tls.VersionTLS13
          }
       case "45":
          // Android API 29
          ext = &tls.PSKKeyExchangeModesExtension{
             Modes: []uint8{tls.PskModeDHE},
          }
+      case "49":
+         // Mozilla Firefox, empty body
+         ext = &tls.GenericExtension{Id: 49}
+      case "50":
+         ext = &tls.SignatureAlgorithmsCertExtension{
+            SupportedSignatureAlgorithms: signature_algorithms,
+         }
+      case "51":
+         // Android API 29
+         ext = &tls.KeyShareExtension{
+            KeyShares: key_shares(info.SupportedCurves),
+         }
+      case "17513":
+         // Google Chrome
+         ext = &tls.ApplicationSettingsExtension{
+            SupportedProtocols: []string{"h2"},
+         }
+      case "65037":
+         // Google Chrome
+         data, err := ech_grease()
+         if err != nil {
+            return nil, err
+         }
+         ext = &tls.GenericExtension{Id: 65037, Data: data}
       case "65281":
          // Android API 24
          ext = &tls.RenegotiationInfoExtension{}
@@ -107,7 +153,12 @@ func Parse_JA3(str string) (*tls.ClientHelloSpec, error) {
          if err != nil {
             return nil, err
          }
-         ext = &tls.GenericExtension{Id: uint16(u)}
+         if is_grease(uint16(u)) {
+            // Google Chrome
+            ext = &tls.UtlsGREASEExtension{Value: uint16(u)}
+         } else {
+            ext = &tls.GenericExtension{Id: uint16(u)}
+         }
       }
       spec.Extensions = append(spec.Extensions, ext)
    }
@@ -115,3 +166,73 @@ func Parse_JA3(str string) (*tls.ClientHelloSpec, error) {
    spec.TLSVersMin = tls.VersionTLS10
    return &spec, nil
 }
+
+var signature_algorithms = []tls.SignatureScheme{
+   // Android API 24
+   tls.ECDSAWithP256AndSHA256,
+   // httpbin.org
+   tls.PKCS1WithSHA256,
+   // TLS 1.3 does not allow PKCS1 with RSA certificates
+   tls.PSSWithSHA256,
+   tls.ECDSAWithP384AndSHA384,
+   tls.PSSWithSHA384,
+   tls.PKCS1WithSHA384,
+   tls.PSSWithSHA512,
+   tls.PKCS1WithSHA512,
+}
+
+// RFC 8701, 0x0A0A, 0x1A1A and so on up to 0xFAFA
+func is_grease(v uint16) bool {
+   return v & 0x0F0F == 0x0A0A && v >> 8 == v & 0xFF
+}
+
+// TLS 1.3 is only offered if a TLS 1.3 cipher suite is. GREASE comes first
+// if the cipher suites start with GREASE, like Google Chrome.
+func supported_versions(suites []uint16) []uint16 {
+   var vers []uint16
+   if len(suites) >= 1 && is_grease(suites[0]) {
+      vers = append(vers, tls.GREASE_PLACEHOLDER)
+   }
+   for _, suite := range suites {
+      if suite >> 8 == 0x13 {
+         vers = append(vers, tls.VersionTLS13)
+         break
+      }
+   }
+   return append(vers, tls.VersionTLS12)
+}
+
+// one share for the first curve uTLS can generate a key for. GREASE comes
+// first if the curves start with GREASE, like Google Chrome.
+func key_shares(curves []tls.CurveID) []tls.KeyShare {
+   var shares []tls.KeyShare
+   for _, curve := range curves {
+      switch {
+      case is_grease(uint16(curve)):
+         if shares == nil {
+            shares = append(shares, tls.KeyShare{
+               Data: []byte{0}, Group: tls.GREASE_PLACEHOLDER,
+            })
+         }
+      case curve == tls.X25519, curve == tls.CurveP256,
+      curve == tls.CurveP384, curve == tls.CurveP521:
+         return append(shares, tls.KeyShare{Group: curve})
+      }
+   }
+   return shares
+}
+
+// draft-ietf-tls-esni section 6.2, outer ClientHello with HKDF-SHA256,
+// AES-128-GCM, random config ID, random enc and random payload
+func ech_grease() ([]byte, error) {
+   buf := make([]byte, 1 + 32 + 144)
+   if _, err := rand.Read(buf); err != nil {
+      return nil, err
+   }
+   var b []byte
+   b = append(b, 0, 0, 1, 0, 1, buf[0])
+   b = append(b, 0, 32)
+   b = append(b, buf[1:33]...)
+   b = append(b, 0, 144)
+   return append(b, buf[33:]...), nil
+}
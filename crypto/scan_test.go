@@ -1,6 +1,11 @@
 package crypto
 
 import (
+   "github.com/refraction-networking/utls"
+   "io"
+   "log"
+   "net"
+   "net/http/httptest"
    "testing"
 )
 
@@ -17,3 +22,66 @@ func Test_Format_JA3(t *testing.T) {
       t.Fatal(ja3)
    }
 }
+
+// Google Chrome 106, with GREASE and encrypted client hello
+const chrome_ja3 =
+   "771,2570-4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-" +
+   "49172-156-157-47-53,2570-0-23-65281-10-11-35-16-5-13-18-51-45-43-27-" +
+   "17513-65037-6682-21,2570-29-23-24,0"
+
+// Mozilla Firefox 105
+const firefox_ja3 =
+   "771,4865-4867-4866-49195-49199-52393-52392-49196-49200-49162-49161-" +
+   "49171-49172-156-157-47-53,0-23-65281-10-11-35-16-5-34-51-43-13-45-28-" +
+   "49-21,29-23-24-25-256-257,0"
+
+var browsers = []string{chrome_ja3, firefox_ja3}
+
+func Test_Round_Trip(t *testing.T) {
+   for _, hello := range append(hellos, browsers...) {
+      spec, err := Parse_JA3(hello)
+      if err != nil {
+         t.Fatal(err)
+      }
+      for _, ext := range spec.Extensions {
+         gen, ok := ext.(*tls.GenericExtension)
+         if ok && gen.Id != 49 && gen.Id != 65037 {
+            t.Fatal("generic", gen.Id, hello)
+         }
+      }
+      ja3, err := Format_JA3(spec)
+      if err != nil {
+         t.Fatal(err)
+      }
+      if ja3 != hello {
+         t.Fatal(ja3)
+      }
+   }
+}
+
+func Test_Handshake(t *testing.T) {
+   server := httptest.NewUnstartedServer(nil)
+   // closing after the handshake is not an error
+   server.Config.ErrorLog = log.New(io.Discard, "", 0)
+   server.StartTLS()
+   defer server.Close()
+   for _, hello := range append(hellos, browsers...) {
+      spec, err := Parse_JA3(hello)
+      if err != nil {
+         t.Fatal(err)
+      }
+      conn, err := net.Dial("tcp", server.Listener.Addr().String())
+      if err != nil {
+         t.Fatal(err)
+      }
+      config := &tls.Config{InsecureSkipVerify: true}
+      uconn := tls.UClient(conn, config, tls.HelloCustom)
+      if err := uconn.ApplyPreset(spec); err != nil {
+         t.Fatal(err)
+      }
+      if err := uconn.Handshake(); err != nil {
+         t.Fatal(err, hello)
+      }
+      uconn.Close()
+   }
+}
@@ -0,0 +1,122 @@
+package crypto
+
+import (
+   "encoding/binary"
+   "errors"
+   "github.com/89z/rosso/strconv"
+)
+
+// buf is a handshake record, starting with 0x16. Anything after the
+// ServerHello, such as the Certificate, is ignored.
+func Parse_Server_Hello(buf []byte) (*Server_Hello, error) {
+   // record header, handshake header
+   if len(buf) < 9 || buf[0] != 0x16 || buf[5] != 2 {
+      return nil, errors.New("not a ServerHello")
+   }
+   size := int(buf[6]) << 16 | int(buf[7]) << 8 | int(buf[8])
+   read := hello_reader(buf[9:])
+   read, ok := read.next(size)
+   if !ok {
+      return nil, errors.New("ServerHello length")
+   }
+   var hello Server_Hello
+   ver, ok := read.uint16()
+   if !ok {
+      return nil, errors.New("ServerHello version")
+   }
+   hello.Version = ver
+   // random
+   if _, ok := read.next(32); !ok {
+      return nil, errors.New("ServerHello random")
+   }
+   // session ID
+   if _, ok := read.vector8(); !ok {
+      return nil, errors.New("ServerHello session ID")
+   }
+   if hello.Cipher_Suite, ok = read.uint16(); !ok {
+      return nil, errors.New("ServerHello cipher suite")
+   }
+   // compression method
+   if _, ok := read.next(1); !ok {
+      return nil, errors.New("ServerHello compression method")
+   }
+   // extensions are optional
+   if len(read) == 0 {
+      return &hello, nil
+   }
+   exts, ok := read.vector16()
+   if !ok {
+      return nil, errors.New("ServerHello extensions")
+   }
+   for len(exts) >= 1 {
+      typ, ok := exts.uint16()
+      if !ok {
+         return nil, errors.New("ServerHello extension type")
+      }
+      data, ok := exts.vector16()
+      if !ok {
+         return nil, errors.New("ServerHello extension data")
+      }
+      hello.Extensions = append(hello.Extensions, typ)
+      switch typ {
+      case 16:
+         protos, _ := data.vector16()
+         proto, _ := protos.vector8()
+         hello.ALPN = string(proto)
+      case 43:
+         hello.Supported_Version, _ = data.uint16()
+      }
+   }
+   return &hello, nil
+}
+
+// SSLVersion,Cipher,SSLExtension
+func Format_JA3S(hello *Server_Hello) string {
+   var b []byte
+   b = strconv.AppendUint(b, hello.Version, 10)
+   b = append(b, ',')
+   b = strconv.AppendUint(b, hello.Cipher_Suite, 10)
+   b = append(b, ',')
+   for key, val := range hello.Extensions {
+      if key >= 1 {
+         b = append(b, '-')
+      }
+      b = strconv.AppendUint(b, val, 10)
+   }
+   return string(b)
+}
+
+type hello_reader []byte
+
+func (h *hello_reader) next(n int) (hello_reader, bool) {
+   if len(*h) < n {
+      return nil, false
+   }
+   buf := (*h)[:n]
+   *h = (*h)[n:]
+   return buf, true
+}
+
+func (h *hello_reader) uint16() (uint16, bool) {
+   buf, ok := h.next(2)
+   if !ok {
+      return 0, false
+   }
+   return binary.BigEndian.Uint16(buf), true
+}
+
+func (h *hello_reader) vector8() (hello_reader, bool) {
+   buf, ok := h.next(1)
+   if !ok {
+      return nil, false
+   }
+   return h.next(int(buf[0]))
+}
+
+func (h *hello_reader) vector16() (hello_reader, bool) {
+   size, ok := h.uint16()
+   if !ok {
+      return nil, false
+   }
+   return h.next(int(size))
+}
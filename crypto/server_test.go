@@ -0,0 +1,75 @@
+package crypto
+
+import (
+   "encoding/hex"
+   "testing"
+)
+
+// Android API 26 against crypto/tls
+const server_hello_12 =
+   "160303004e0200004a030389c4d8cde586aa9f2eed1b98b2ce6c7a0b95fc22b91f80e5" +
+   "444f574e4752440100c02f00002200230000ff01000100001700000010000b00090868" +
+   "7474702f312e31000b00020100"
+
+// Android API 29 against crypto/tls
+const server_hello_13 =
+   "160303007a020000760303aafe01c7402186ea8a6a23d5111fa79c35fd83fe5d2c6b62" +
+   "ad6c43b32ee4d0a22051f36898f6fe21ee6977eba6983b878be1338311c53d67b379c4" +
+   "c64e1677278d130100002e002b0002030400330024001d0020b48db5bd4f10851fdc37" +
+   "754f41341d0d04cc6c24bb53eff224f80cadf7098843"
+
+var server_hellos = []struct {
+   hand string
+   ja3s string
+   hash string
+   ja4s string
+}{
+   {
+      server_hello_12,
+      "771,49199,35-65281-23-16-11",
+      "b8de6ca027c0498f7cd018762c2d8a98",
+      "t1205h1_c02f_",
+   },
+   {
+      server_hello_13,
+      "771,4865,43-51",
+      "f4febc55ea12b31ae17cfb7e614afda8",
+      "t130200_1301_",
+   },
+}
+
+func Test_Server_Hello(t *testing.T) {
+   for _, test := range server_hellos {
+      data, err := hex.DecodeString(test.hand)
+      if err != nil {
+         t.Fatal(err)
+      }
+      hello, err := Parse_Server_Hello(data)
+      if err != nil {
+         t.Fatal(err)
+      }
+      ja3s := Format_JA3S(hello)
+      if ja3s != test.ja3s {
+         t.Fatal(ja3s)
+      }
+      if hash := Fingerprint(ja3s); hash != test.hash {
+         t.Fatal(hash)
+      }
+      ja4s := Format_JA4S(hello)
+      if ja4s[:len(test.ja4s)] != test.ja4s {
+         t.Fatal(ja4s)
+      }
+   }
+}
+
+func Test_Server_Hello_Short(t *testing.T) {
+   data, err := hex.DecodeString(server_hello_12)
+   if err != nil {
+      t.Fatal(err)
+   }
+   for _, size := range []int{0, 9, 40, 80} {
+      if _, err := Parse_Server_Hello(data[:size]); err == nil {
+         t.Fatal(size)
+      }
+   }
+}
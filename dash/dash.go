@@ -1,8 +1,10 @@
 package dash
 
 import (
+   "encoding/xml"
    "strconv"
    "strings"
+   "time"
 )
 
 func (r Representations) Filter(f func(Representation) bool) Representations {
@@ -27,6 +29,16 @@ func (r Representations) Audio() Representations {
    })
 }
 
+func (r Representations) Text() Representations {
+   return r.Filter(func(a Representation) bool {
+      switch a.Ext() {
+      case ".mp4", ".ttml", ".vtt":
+         return true
+      }
+      return a.Adaptation.ContentType == "text"
+   })
+}
+
 func (r Representations) Index(f func(a, b Representation) bool) int {
    carry := -1
    for i, item := range r {
@@ -53,7 +65,7 @@ func (r Representation) String() string {
    var b []byte
    b = append(b, "ID:"...)
    b = append(b, r.ID...)
-   if r.Width + r.Bandwidth >= 1 {
+   if r.Width + r.Bandwidth >= 1 || r.AudioSamplingRate != "" {
       b = append(b, "\n  "...)
    }
    if r.Width >= 1 {
@@ -61,11 +73,30 @@ func (r Representation) String() string {
       b = strconv.AppendInt(b, r.Width, 10)
       b = append(b, " Height:"...)
       b = strconv.AppendInt(b, r.Height, 10)
+      if r.FrameRate != "" {
+         b = append(b, " FrameRate:"...)
+         b = append(b, r.FrameRate...)
+      }
+      if r.Sar != "" {
+         b = append(b, " SAR:"...)
+         b = append(b, r.Sar...)
+      }
    }
-   if r.Bandwidth >= 1 {
+   if r.AudioSamplingRate != "" {
       if r.Width >= 1 {
          b = append(b, ' ')
       }
+      b = append(b, "SamplingRate:"...)
+      b = append(b, r.AudioSamplingRate...)
+      if r.AudioChannelConfiguration != nil {
+         b = append(b, " Channels:"...)
+         b = append(b, r.AudioChannelConfiguration.Value...)
+      }
+   }
+   if r.Bandwidth >= 1 {
+      if r.Width >= 1 || r.AudioSamplingRate != "" {
+         b = append(b, ' ')
+      }
       b = append(b, "Bandwidth:"...)
       b = strconv.AppendInt(b, r.Bandwidth, 10)
    }
@@ -83,13 +114,53 @@ func (r Representation) String() string {
       b = append(b, " Role:"...)
       b = append(b, r.Adaptation.Role.Value...)
    }
+   if r.Label != "" {
+      b = append(b, "\n  Label:"...)
+      b = append(b, r.Label...)
+   }
+   for _, acc := range r.Adaptation.Accessibility {
+      b = append(b, "\n  Accessibility:"...)
+      b = append(b, acc.Value...)
+   }
+   for _, prop := range r.EssentialProperty {
+      b = append(b, "\n  EssentialProperty:"...)
+      b = append(b, prop.String()...)
+   }
+   for _, prop := range r.SupplementalProperty {
+      b = append(b, "\n  SupplementalProperty:"...)
+      b = append(b, prop.String()...)
+   }
    return string(b)
 }
 
 type Segment struct {
    D int `xml:"d,attr"` // duration
+   N int `xml:"n,attr"` // number
    R int `xml:"r,attr"` // repeat
    T int `xml:"t,attr"` // time
+   // if @n and @t were present, as zero is a valid value
+   has_n bool
+   has_t bool
+}
+
+func (s *Segment) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+   type segment Segment
+   if err := d.DecodeElement((*segment)(s), &start); err != nil {
+      return err
+   }
+   for _, attr := range start.Attr {
+      switch attr.Name.Local {
+      case "n":
+         s.has_n = true
+      case "t":
+         s.has_t = true
+      }
+   }
+   return nil
+}
+
+func (s Segment) Number() string {
+   return strconv.Itoa(s.N)
 }
 
 func (s Segment) Time() string {
@@ -97,33 +168,95 @@ func (s Segment) Time() string {
 }
 
 type SegmentTemplate struct {
+   // segment duration, if there is no SegmentTimeline
+   Duration int `xml:"duration,attr"`
    Initialization string `xml:"initialization,attr"`
    Media string `xml:"media,attr"`
+   PresentationTimeOffset int `xml:"presentationTimeOffset,attr"`
    SegmentTimeline struct {
       S []Segment
    }
    StartNumber *int `xml:"startNumber,attr"`
+   Timescale int64 `xml:"timescale,attr"`
+}
+
+func (s SegmentTemplate) Time(v int) Time {
+   return Time{int64(v), s.Timescale}
+}
+
+// inverse of Time
+func (s SegmentTemplate) ticks(d time.Duration) int {
+   scale := s.Timescale
+   if scale <= 0 {
+      scale = 1
+   }
+   sec := int64(d / time.Second)
+   rem := int64(d % time.Second)
+   return int(sec * scale + rem * scale / int64(time.Second))
+}
+
+// single file, with the segments listed in the sidx at IndexRange
+type SegmentBase struct {
+   IndexRange string `xml:"indexRange,attr"`
+   Initialization struct {
+      Range string `xml:"range,attr"`
+   }
 }
 
 type Representations []Representation
 
 func (p Presentation) Representation() Representations {
+   var period time.Duration
+   if p.Period.Duration != nil {
+      period = p.Period.Duration.Duration()
+   } else if p.MediaPresentationDuration != nil {
+      period = p.MediaPresentationDuration.Duration()
+   }
    var reps []Representation
    for i, ada := range p.Period.AdaptationSet {
       for _, rep := range ada.Representation {
          rep.Adaptation = &p.Period.AdaptationSet[i]
+         if rep.AudioChannelConfiguration == nil {
+            rep.AudioChannelConfiguration = ada.AudioChannelConfiguration
+         }
+         if rep.AudioSamplingRate == "" {
+            rep.AudioSamplingRate = ada.AudioSamplingRate
+         }
+         if rep.BaseURL == "" {
+            rep.BaseURL = ada.BaseURL
+         }
          if rep.Codecs == "" {
             rep.Codecs = ada.Codecs
          }
          if rep.ContentProtection == nil {
             rep.ContentProtection = ada.ContentProtection
          }
+         // properties on both levels apply
+         rep.EssentialProperty = append(
+            rep.EssentialProperty, ada.EssentialProperty...,
+         )
+         if rep.FrameRate == "" {
+            rep.FrameRate = ada.FrameRate
+         }
+         if rep.Label == "" {
+            rep.Label = ada.Label
+         }
          if rep.MimeType == "" {
             rep.MimeType = ada.MimeType
          }
+         rep.period = period
+         if rep.Sar == "" {
+            rep.Sar = ada.Sar
+         }
+         if rep.SegmentBase == nil {
+            rep.SegmentBase = ada.SegmentBase
+         }
          if rep.SegmentTemplate == nil {
             rep.SegmentTemplate = ada.SegmentTemplate
          }
+         rep.SupplementalProperty = append(
+            rep.SupplementalProperty, ada.SupplementalProperty...,
+         )
          reps = append(reps, rep)
       }
    }
@@ -132,36 +265,87 @@ func (p Presentation) Representation() Representations {
 
 type Representation struct {
    Adaptation *Adaptation
+   AudioChannelConfiguration *Descriptor
+   AudioSamplingRate string `xml:"audioSamplingRate,attr"`
    Bandwidth int64 `xml:"bandwidth,attr"`
+   BaseURL string
    Codecs string `xml:"codecs,attr"`
    ContentProtection *ContentProtection
+   EssentialProperty []Descriptor
+   FrameRate string `xml:"frameRate,attr"`
    Height int64 `xml:"height,attr"`
    ID string `xml:"id,attr"`
+   Label string
    MimeType string `xml:"mimeType,attr"`
+   Sar string `xml:"sar,attr"`
+   SegmentBase *SegmentBase
    SegmentTemplate *SegmentTemplate
+   SupplementalProperty []Descriptor
    Width int64 `xml:"width,attr"`
+   period time.Duration
 }
 
 type Adaptation struct {
+   Accessibility []Descriptor
+   AudioChannelConfiguration *Descriptor
+   AudioSamplingRate string `xml:"audioSamplingRate,attr"`
+   BaseURL string
    Codecs string `xml:"codecs,attr"`
    ContentProtection *ContentProtection
+   ContentType string `xml:"contentType,attr"`
+   EssentialProperty []Descriptor
+   FrameRate string `xml:"frameRate,attr"`
+   Label string
    Lang string `xml:"lang,attr"`
    MimeType string `xml:"mimeType,attr"`
-   Role *struct {
-      Value string `xml:"value,attr"`
-   }
+   Role *Descriptor
+   Sar string `xml:"sar,attr"`
+   SegmentBase *SegmentBase
    SegmentTemplate *SegmentTemplate
+   SupplementalProperty []Descriptor
    Representation []Representation
 }
 
+// Role, Accessibility, AudioChannelConfiguration and the properties all share
+// this type
+type Descriptor struct {
+   SchemeIdUri string `xml:"schemeIdUri,attr"`
+   Value string `xml:"value,attr"`
+}
+
+func (d Descriptor) String() string {
+   return d.SchemeIdUri + " " + d.Value
+}
+
+// value of the first EssentialProperty or SupplementalProperty with the given
+// scheme, for example urn:mpeg:mpegB:cicp:TransferCharacteristics
+func (r Representation) Property(scheme string) (string, bool) {
+   for _, prop := range r.EssentialProperty {
+      if prop.SchemeIdUri == scheme {
+         return prop.Value, true
+      }
+   }
+   for _, prop := range r.SupplementalProperty {
+      if prop.SchemeIdUri == scheme {
+         return prop.Value, true
+      }
+   }
+   return "", false
+}
+
 type ContentProtection struct {
    Default_KID string `xml:"default_KID,attr"`
 }
 
 type Presentation struct {
+   MediaPresentationDuration *Duration `xml:"mediaPresentationDuration,attr"`
+   MinBufferTime *Duration `xml:"minBufferTime,attr"`
    Period struct {
       AdaptationSet []Adaptation
+      Duration *Duration `xml:"duration,attr"`
+      Start *Duration `xml:"start,attr"`
    }
+   TimeShiftBufferDepth *Duration `xml:"timeShiftBufferDepth,attr"`
 }
 
 func (r Representation) Ext() string {
@@ -170,10 +354,28 @@ func (r Representation) Ext() string {
       return ".m4v"
    case "audio/mp4":
       return ".m4a"
+   case "text/vtt":
+      return ".vtt"
+   case "application/ttml+xml":
+      return ".ttml"
+   }
+   // fragmented MP4 with stpp or wvtt samples
+   if r.text_track() {
+      return ".mp4"
    }
    return ""
 }
 
+func (r Representation) text_track() bool {
+   if r.MimeType != "application/mp4" {
+      return false
+   }
+   if strings.HasPrefix(r.Codecs, "stpp") {
+      return true
+   }
+   return strings.HasPrefix(r.Codecs, "wvtt")
+}
+
 func (r Representation) Role() string {
    if r.Adaptation.Role == nil {
       return ""
@@ -78,6 +78,31 @@ func Test_Video(t *testing.T) {
    }
 }
 
+func Test_Text(t *testing.T) {
+   for _, name := range tests {
+      file, err := os.Open(name)
+      if err != nil {
+         t.Fatal(err)
+      }
+      var pre Presentation
+      if err := xml.NewDecoder(file).Decode(&pre); err != nil {
+         t.Fatal(err)
+      }
+      if err := file.Close(); err != nil {
+         t.Fatal(err)
+      }
+      fmt.Println(name)
+      for _, rep := range pre.Representation().Text() {
+         media := rep.Media()
+         if len(media) == 0 {
+            t.Fatal(name, rep.ID, "no media")
+         }
+         fmt.Printf("%q %q\n", rep.Ext(), media[0])
+      }
+      fmt.Println()
+   }
+}
+
 func Test_Info(t *testing.T) {
    for _, name := range tests {
       file, err := os.Open(name)
@@ -99,6 +124,9 @@ func Test_Info(t *testing.T) {
       for _, rep := range reps.Audio() {
          fmt.Println(rep)
       }
+      for _, rep := range reps.Text() {
+         fmt.Println(rep)
+      }
       fmt.Println()
    }
 }
@@ -122,3 +150,54 @@ func Test_Ext(t *testing.T) {
       fmt.Println()
    }
 }
+
+const hdr_adaptation = `
+<MPD>
+   <Period>
+      <AdaptationSet contentType="video" frameRate="24" mimeType="video/mp4">
+         <SupplementalProperty
+            schemeIdUri="urn:mpeg:mpegB:cicp:TransferCharacteristics" value="16"
+         />
+         <Representation id="hdr10" codecs="hvc1.2.4.L150.90" width="3840" height="2160"/>
+      </AdaptationSet>
+      <AdaptationSet contentType="audio" lang="en" mimeType="audio/mp4">
+         <Accessibility schemeIdUri="urn:tva:metadata:cs:AudioPurposeCS:2007" value="1"/>
+         <AudioChannelConfiguration
+            schemeIdUri="urn:mpeg:dash:23003:3:audio_channel_configuration:2011"
+            value="6"
+         />
+         <Label>English (Audio Description)</Label>
+         <Representation id="ad" audioSamplingRate="48000" codecs="ec-3"/>
+      </AdaptationSet>
+   </Period>
+</MPD>
+`
+
+func Test_Attributes(t *testing.T) {
+   var pre Presentation
+   err := xml.Unmarshal([]byte(hdr_adaptation), &pre)
+   if err != nil {
+      t.Fatal(err)
+   }
+   reps := pre.Representation()
+   video := reps.Video()[0]
+   if video.FrameRate != "24" {
+      t.Fatal(video.FrameRate)
+   }
+   transfer, ok := video.Property("urn:mpeg:mpegB:cicp:TransferCharacteristics")
+   if !ok || transfer != "16" {
+      t.Fatal(transfer)
+   }
+   audio := reps.Audio()[0]
+   if audio.AudioChannelConfiguration.Value != "6" {
+      t.Fatal(audio.AudioChannelConfiguration)
+   }
+   if audio.Label != "English (Audio Description)" {
+      t.Fatal(audio.Label)
+   }
+   if len(audio.Adaptation.Accessibility) != 1 {
+      t.Fatal(audio.Adaptation.Accessibility)
+   }
+   fmt.Println(video)
+   fmt.Println(audio)
+}
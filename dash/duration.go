@@ -0,0 +1,110 @@
+package dash
+
+import (
+   "encoding/xml"
+   "errors"
+   "strings"
+   "time"
+)
+
+// xs:duration, for example PT42M45.568S. Years and months have no fixed
+// length, so they are counted as 365 and 30 days.
+type Duration time.Duration
+
+func Parse_Duration(s string) (time.Duration, error) {
+   neg := strings.HasPrefix(s, "-")
+   s = strings.TrimPrefix(s, "-")
+   if !strings.HasPrefix(s, "P") || len(s) == 1 {
+      return 0, errors.New("invalid duration " + s)
+   }
+   s = s[1:]
+   var (
+      carry time.Duration
+      clock bool
+      num string
+   )
+   for _, r := range s {
+      if r == 'T' {
+         if clock || num != "" {
+            return 0, errors.New("invalid duration " + s)
+         }
+         clock = true
+         continue
+      }
+      if r == '.' || r >= '0' && r <= '9' {
+         num += string(r)
+         continue
+      }
+      // time.ParseDuration accepts decimals for any unit
+      var (
+         unit string
+         factor time.Duration = 1
+      )
+      switch {
+      case r == 'Y' && !clock:
+         unit, factor = "h", 365 * 24
+      case r == 'M' && !clock:
+         unit, factor = "h", 30 * 24
+      case r == 'W' && !clock:
+         unit, factor = "h", 7 * 24
+      case r == 'D' && !clock:
+         unit, factor = "h", 24
+      case r == 'H' && clock:
+         unit = "h"
+      case r == 'M' && clock:
+         unit = "m"
+      case r == 'S' && clock:
+         unit = "s"
+      default:
+         return 0, errors.New("invalid duration " + s)
+      }
+      if num == "" {
+         return 0, errors.New("invalid duration " + s)
+      }
+      dur, err := time.ParseDuration(num + unit)
+      if err != nil {
+         return 0, err
+      }
+      carry += dur * factor
+      num = ""
+   }
+   if num != "" || strings.HasSuffix(s, "T") {
+      return 0, errors.New("invalid duration " + s)
+   }
+   if neg {
+      return -carry, nil
+   }
+   return carry, nil
+}
+
+func (d Duration) Duration() time.Duration {
+   return time.Duration(d)
+}
+
+func (d *Duration) UnmarshalXMLAttr(attr xml.Attr) error {
+   dur, err := Parse_Duration(attr.Value)
+   if err != nil {
+      return err
+   }
+   *d = Duration(dur)
+   return nil
+}
+
+// value in units of timescale ticks per second
+type Time struct {
+   Value int64
+   Timescale int64
+}
+
+func (t Time) Duration() time.Duration {
+   scale := t.Timescale
+   // timescale defaults to 1
+   if scale <= 0 {
+      scale = 1
+   }
+   // avoid overflow with large values
+   sec := t.Value / scale
+   rem := t.Value % scale
+   return time.Duration(sec) * time.Second +
+      time.Duration(rem) * time.Second / time.Duration(scale)
+}
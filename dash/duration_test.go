@@ -0,0 +1,71 @@
+package dash
+
+import (
+   "encoding/xml"
+   "fmt"
+   "os"
+   "testing"
+   "time"
+)
+
+var durations = map[string]time.Duration{
+   "P1DT2H": 26 * time.Hour,
+   "PT0.000S": 0,
+   "PT2636.00830078125S": 2636008300781 * time.Nanosecond,
+   "PT2S": 2 * time.Second,
+   "PT42M45.568S": 42 * time.Minute + 45568 * time.Millisecond,
+   "-PT30S": -30 * time.Second,
+}
+
+func Test_Parse_Duration(t *testing.T) {
+   for in, out := range durations {
+      dur, err := Parse_Duration(in)
+      if err != nil {
+         t.Fatal(err)
+      }
+      if dur != out {
+         t.Fatal(in, dur)
+      }
+   }
+   for _, in := range []string{"", "P", "PT", "T1S", "PT1", "P1H", "PT1D"} {
+      if _, err := Parse_Duration(in); err == nil {
+         t.Fatal(in)
+      }
+   }
+}
+
+func Test_Time(t *testing.T) {
+   dur := Time{Value: 288768, Timescale: 48000}.Duration()
+   if dur != 6016 * time.Millisecond {
+      t.Fatal(dur)
+   }
+   dur = Time{Value: 6}.Duration()
+   if dur != 6 * time.Second {
+      t.Fatal(dur)
+   }
+}
+
+func Test_Duration(t *testing.T) {
+   for _, name := range tests {
+      file, err := os.Open(name)
+      if err != nil {
+         t.Fatal(err)
+      }
+      var pre Presentation
+      if err := xml.NewDecoder(file).Decode(&pre); err != nil {
+         t.Fatal(err)
+      }
+      if err := file.Close(); err != nil {
+         t.Fatal(err)
+      }
+      if pre.MediaPresentationDuration == nil {
+         t.Fatal(name, "no mediaPresentationDuration")
+      }
+      fmt.Println(name, pre.MediaPresentationDuration.Duration())
+      for _, rep := range pre.Representation() {
+         dur := rep.Duration()
+         fmt.Println(rep.ID, dur, rep.Seek(dur / 2), len(rep.Media()))
+      }
+      fmt.Println()
+   }
+}
@@ -0,0 +1,176 @@
+package dash
+
+import (
+   "bytes"
+   "errors"
+   "github.com/89z/rosso/hls"
+   "github.com/edgeware/mp4ff/mp4"
+   "strconv"
+   "strings"
+)
+
+// URI of each media playlist is the Representation ID with .m3u8 appended
+func (r Representation) Playlist_URI() string {
+   return r.ID + ".m3u8"
+}
+
+// Audio is grouped by codec, as HLS requires renditions in a group to share
+// one codec. Each video is then paired with every audio group.
+func (p Presentation) Master() hls.Master {
+   var (
+      groups []string
+      mas hls.Master
+      peak = make(map[string]int64)
+   )
+   reps := p.Representation()
+   video := reps.Video()
+   audio := reps.Audio()
+   if len(video) == 0 {
+      video, audio = audio, nil
+   }
+   for _, rep := range audio {
+      group := "audio-" + rep.Codecs
+      _, ok := peak[group]
+      if !ok {
+         groups = append(groups, group)
+      }
+      if rep.Bandwidth > peak[group] {
+         peak[group] = rep.Bandwidth
+      }
+      // first of each group is the default
+      med := hls.Medium{
+         Autoselect: true,
+         Default: !ok,
+         Group_ID: group,
+         Language: rep.Adaptation.Lang,
+         Name: rep.ID,
+         Raw_URI: rep.Playlist_URI(),
+         Type: "AUDIO",
+      }
+      if rep.Role() == "description" {
+         med.Characteristics = "public.accessibility.describes-video"
+      }
+      mas.Media = append(mas.Media, med)
+   }
+   text := reps.Text()
+   for _, rep := range text {
+      mas.Media = append(mas.Media, hls.Medium{
+         Autoselect: true,
+         Group_ID: "subs",
+         Language: rep.Adaptation.Lang,
+         Name: rep.ID,
+         Raw_URI: rep.Playlist_URI(),
+         Type: "SUBTITLES",
+      })
+   }
+   // video only, or audio only
+   if groups == nil {
+      groups = []string{""}
+   }
+   for _, rep := range video {
+      for _, group := range groups {
+         str := hls.Stream{
+            Bandwidth: rep.Bandwidth + peak[group],
+            Codecs: rep.Codecs,
+            Raw_URI: rep.Playlist_URI(),
+         }
+         if group != "" {
+            str.Audio = group
+            str.Codecs += "," + strings.TrimPrefix(group, "audio-")
+         }
+         if rep.Width >= 1 {
+            var b []byte
+            b = strconv.AppendInt(b, rep.Width, 10)
+            b = append(b, 'x')
+            b = strconv.AppendInt(b, rep.Height, 10)
+            str.Resolution = string(b)
+         }
+         if text != nil {
+            str.Subtitles = "subs"
+         }
+         mas.Streams = append(mas.Streams, str)
+      }
+   }
+   return mas
+}
+
+// index is the sidx at SegmentBase@indexRange, and is only needed with
+// SegmentBase
+func (r Representation) Playlist(index []byte) (*hls.Playlist, error) {
+   var play hls.Playlist
+   switch {
+   case r.SegmentTemplate != nil:
+      play.Map = r.Initialization()
+      refs := r.Media()
+      segs := r.segments()
+      if len(segs) == 0 {
+         return nil, errors.New("SegmentTemplate has no segments " + r.ID)
+      }
+      for i, seg := range segs {
+         play.Items = append(play.Items, hls.Item{
+            Duration: r.SegmentTemplate.Time(seg.D).Duration(),
+            URI: refs[i],
+         })
+      }
+   case r.SegmentBase != nil:
+      init, err := parse_range(r.SegmentBase.Initialization.Range)
+      if err != nil {
+         return nil, err
+      }
+      play.Map = r.BaseURL
+      play.Map_Range = init
+      indexes, err := parse_range(r.SegmentBase.IndexRange)
+      if err != nil {
+         return nil, err
+      }
+      box, err := mp4.DecodeBox(0, bytes.NewReader(index))
+      if err != nil {
+         return nil, err
+      }
+      sidx, ok := box.(*mp4.SidxBox)
+      if !ok {
+         return nil, errors.New("sidx not found")
+      }
+      // first_offset is from the first byte after the sidx
+      offset := indexes.Offset + indexes.Length + int64(sidx.FirstOffset)
+      for _, ref := range sidx.SidxRefs {
+         dur := Time{int64(ref.SubSegmentDuration), int64(sidx.Timescale)}
+         play.Items = append(play.Items, hls.Item{
+            Duration: dur.Duration(),
+            Range: &hls.Byte_Range{
+               Length: int64(ref.ReferencedSize), Offset: offset,
+            },
+            URI: r.BaseURL,
+         })
+         offset += int64(ref.ReferencedSize)
+      }
+   case r.BaseURL != "":
+      // sidecar file, so one segment for the whole period
+      play.Items = append(play.Items, hls.Item{
+         Duration: r.period, URI: r.BaseURL,
+      })
+   default:
+      return nil, errors.New("no SegmentTemplate, SegmentBase or BaseURL")
+   }
+   return &play, nil
+}
+
+// for example 0-861
+func parse_range(s string) (*hls.Byte_Range, error) {
+   first, last, ok := strings.Cut(s, "-")
+   if !ok {
+      return nil, errors.New("invalid range " + s)
+   }
+   start, err := strconv.ParseInt(first, 10, 64)
+   if err != nil {
+      return nil, err
+   }
+   end, err := strconv.ParseInt(last, 10, 64)
+   if err != nil {
+      return nil, err
+   }
+   if end < start {
+      return nil, errors.New("invalid range " + s)
+   }
+   return &hls.Byte_Range{Length: end - start + 1, Offset: start}, nil
+}
@@ -0,0 +1,135 @@
+package dash
+
+import (
+   "bytes"
+   "encoding/xml"
+   "fmt"
+   "github.com/edgeware/mp4ff/mp4"
+   "os"
+   "strings"
+   "testing"
+)
+
+func Test_Master(t *testing.T) {
+   for _, name := range tests {
+      file, err := os.Open(name)
+      if err != nil {
+         t.Fatal(err)
+      }
+      var pre Presentation
+      if err := xml.NewDecoder(file).Decode(&pre); err != nil {
+         t.Fatal(err)
+      }
+      if err := file.Close(); err != nil {
+         t.Fatal(err)
+      }
+      fmt.Println(name)
+      fmt.Println(pre.Master().M3U8())
+      for _, rep := range pre.Representation().Text() {
+         play, err := rep.Playlist(nil)
+         if err != nil {
+            t.Fatal(err)
+         }
+         if len(play.Items) == 0 {
+            t.Fatal(rep.ID)
+         }
+      }
+   }
+}
+
+const segment_base = `
+<MPD>
+   <Period>
+      <AdaptationSet mimeType="video/mp4">
+         <Representation id="v" bandwidth="1" codecs="avc1.64001f">
+            <BaseURL>video.mp4</BaseURL>
+            <SegmentBase indexRange="700-799">
+               <Initialization range="0-699"/>
+            </SegmentBase>
+         </Representation>
+      </AdaptationSet>
+   </Period>
+</MPD>
+`
+
+func Test_Segment_Base(t *testing.T) {
+   var pre Presentation
+   err := xml.Unmarshal([]byte(segment_base), &pre)
+   if err != nil {
+      t.Fatal(err)
+   }
+   sidx := mp4.SidxBox{Timescale: 1000, FirstOffset: 10}
+   sidx.SidxRefs = []mp4.SidxRef{
+      {ReferencedSize: 5000, SubSegmentDuration: 2000},
+      {ReferencedSize: 3000, SubSegmentDuration: 1500},
+   }
+   var buf bytes.Buffer
+   if err := sidx.Encode(&buf); err != nil {
+      t.Fatal(err)
+   }
+   play, err := pre.Representation()[0].Playlist(buf.Bytes())
+   if err != nil {
+      t.Fatal(err)
+   }
+   text := play.M3U8()
+   for _, line := range []string{
+      `#EXT-X-MAP:URI="video.mp4",BYTERANGE="700@0"`,
+      "#EXT-X-BYTERANGE:5000@810",
+      "#EXT-X-BYTERANGE:3000@5810",
+      "#EXTINF:1.500,",
+      "#EXT-X-TARGETDURATION:2",
+   } {
+      if !strings.Contains(text, line) {
+         t.Fatal(text)
+      }
+   }
+}
+
+const segment_duration = `
+<MPD mediaPresentationDuration="PT9S">
+   <Period>
+      <AdaptationSet mimeType="audio/mp4" lang="en">
+         <SegmentTemplate media="$Number$.m4s" duration="4" startNumber="1"/>
+         <Representation id="a" bandwidth="1" codecs="mp4a.40.2"/>
+         <Representation id="b" bandwidth="2" codecs="mp4a.40.2"/>
+      </AdaptationSet>
+      <AdaptationSet mimeType="video/mp4">
+         <SegmentTemplate media="$Number$.m4s" duration="4" startNumber="1"/>
+         <Representation id="v" bandwidth="3" codecs="avc1.64001f"/>
+      </AdaptationSet>
+   </Period>
+</MPD>
+`
+
+func Test_Segment_Duration(t *testing.T) {
+   var pre Presentation
+   err := xml.Unmarshal([]byte(segment_duration), &pre)
+   if err != nil {
+      t.Fatal(err)
+   }
+   play, err := pre.Representation()[0].Playlist(nil)
+   if err != nil {
+      t.Fatal(err)
+   }
+   var refs []string
+   for _, item := range play.Items {
+      refs = append(refs, item.URI)
+   }
+   if fmt.Sprint(refs) != "[1.m4s 2.m4s 3.m4s]" {
+      t.Fatal(refs)
+   }
+   text := pre.Master().M3U8()
+   for _, line := range []string{
+      `NAME="a",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,`,
+      `NAME="b",LANGUAGE="en",AUTOSELECT=YES,`,
+   } {
+      if !strings.Contains(text, line) {
+         t.Fatal(text)
+      }
+   }
+   // no Period duration, so no way to know the segment count
+   pre.MediaPresentationDuration = nil
+   if _, err := pre.Representation()[0].Playlist(nil); err == nil {
+      t.Fatal("no error")
+   }
+}
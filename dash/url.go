@@ -2,32 +2,114 @@ package dash
 
 import (
    "strings"
+   "time"
 )
 
 func (r Representation) Initialization() string {
+   // sidecar files have no initialization
+   if r.SegmentTemplate == nil {
+      return ""
+   }
    return r.replace_ID(r.SegmentTemplate.Initialization)
 }
 
 func (r Representation) Media() []string {
-   var start int
-   if r.SegmentTemplate.StartNumber != nil {
-      start = *r.SegmentTemplate.StartNumber
+   // sidecar files such as a single VTT, point at the file with BaseURL
+   if r.SegmentTemplate == nil {
+      if r.BaseURL == "" {
+         return nil
+      }
+      return []string{r.BaseURL}
    }
    var refs []string
-   for _, seg := range r.SegmentTemplate.SegmentTimeline.S {
-      for seg.T = start; seg.R >= 0; seg.R-- {
-         ref := r.replace_ID(r.SegmentTemplate.Media)
-         if r.SegmentTemplate.StartNumber != nil {
-            ref = strings.Replace(ref, "$Number$", seg.Time(), 1)
-            seg.T++
-            start++
-         } else {
-            ref = strings.Replace(ref, "$Time$", seg.Time(), 1)
-            seg.T += seg.D
-            start += seg.D
+   for _, seg := range r.segments() {
+      ref := r.replace_ID(r.SegmentTemplate.Media)
+      ref = strings.Replace(ref, "$Number$", seg.Number(), 1)
+      ref = strings.Replace(ref, "$Time$", seg.Time(), 1)
+      refs = append(refs, ref)
+   }
+   return refs
+}
+
+// SegmentTimeline with repeats expanded, so each Segment is one Media
+// reference with both T and N set
+func (r Representation) segments() []Segment {
+   if r.SegmentTemplate == nil {
+      return nil
+   }
+   number := 1
+   if r.SegmentTemplate.StartNumber != nil {
+      number = *r.SegmentTemplate.StartNumber
+   }
+   var (
+      segs []Segment
+      start int
+   )
+   timeline := r.SegmentTemplate.SegmentTimeline.S
+   // without SegmentTimeline, @duration repeats until the end of the Period
+   if timeline == nil && r.SegmentTemplate.Duration >= 1 && r.period >= 1 {
+      timeline = []Segment{{
+         D: r.SegmentTemplate.Duration,
+         R: -1,
+         T: r.SegmentTemplate.PresentationTimeOffset,
+         has_t: true,
+      }}
+   }
+   for i, seg := range timeline {
+      // discontinuity
+      if seg.has_t {
+         start = seg.T
+      }
+      if seg.has_n {
+         number = seg.N
+      }
+      repeat := seg.R
+      // repeat until the next S or the end of the Period
+      if repeat < 0 {
+         var end int
+         // round down, so the last segment does not overlap the next S
+         if i+1 < len(timeline) && timeline[i+1].has_t {
+            end = timeline[i+1].T
+         } else if r.period >= 1 {
+            end = r.SegmentTemplate.PresentationTimeOffset
+            end += r.SegmentTemplate.ticks(r.period)
+            // round up, the last segment of the Period can be short
+            end += seg.D - 1
+         }
+         repeat = 0
+         if seg.D >= 1 && end - start >= seg.D {
+            repeat = (end - start) / seg.D - 1
          }
-         refs = append(refs, ref)
+      }
+      for ; repeat >= 0; repeat-- {
+         segs = append(segs, Segment{
+            D: seg.D, N: number, T: start, has_n: true, has_t: true,
+         })
+         number++
+         start += seg.D
       }
    }
-   return refs
+   return segs
+}
+
+// end of the last segment, from presentationTimeOffset as with Seek
+func (r Representation) Duration() time.Duration {
+   segs := r.segments()
+   if len(segs) == 0 {
+      return 0
+   }
+   last := segs[len(segs)-1]
+   end := last.T - r.SegmentTemplate.PresentationTimeOffset + last.D
+   return r.SegmentTemplate.Time(end).Duration()
+}
+
+// index into Media of the segment that contains the given time
+func (r Representation) Seek(d time.Duration) int {
+   for i, seg := range r.segments() {
+      end := seg.T - r.SegmentTemplate.PresentationTimeOffset + seg.D
+      if d < r.SegmentTemplate.Time(end).Duration() {
+         return i
+      }
+   }
+   return -1
 }
@@ -6,6 +6,7 @@ import (
    "net/http"
    "os"
    "testing"
+   "time"
 )
 
 func Test_Media(t *testing.T) {
@@ -33,3 +34,53 @@ func Test_Media(t *testing.T) {
       fmt.Println(req.URL)
    }
 }
+
+const timeline = `
+<MPD mediaPresentationDuration="PT10S">
+   <Period>
+      <AdaptationSet mimeType="video/mp4">
+         <SegmentTemplate media="$Number$-$Time$" timescale="10" startNumber="5"
+            presentationTimeOffset="100"
+         >
+            <SegmentTimeline>
+               <S t="100" d="20" r="-1"/>
+               <S t="150" d="10"/>
+               <S d="10" n="20"/>
+               <S t="170" d="10" r="-1"/>
+            </SegmentTimeline>
+         </SegmentTemplate>
+         <Representation id="v"/>
+      </AdaptationSet>
+   </Period>
+</MPD>
+`
+
+func Test_Timeline(t *testing.T) {
+   var pre Presentation
+   err := xml.Unmarshal([]byte(timeline), &pre)
+   if err != nil {
+      t.Fatal(err)
+   }
+   refs := pre.Representation()[0].Media()
+   media := []string{
+      // repeat until the next S
+      "5-100", "6-120",
+      "7-150", "20-160",
+      // repeat until the end of the Period
+      "21-170", "22-180", "23-190",
+   }
+   if fmt.Sprint(refs) != fmt.Sprint(media) {
+      t.Fatal(refs)
+   }
+   // both from presentationTimeOffset
+   rep := pre.Representation()[0]
+   if dur := rep.Duration(); dur != 10 * time.Second {
+      t.Fatal(dur)
+   }
+   if i := rep.Seek(rep.Duration() - 1); i != len(media) - 1 {
+      t.Fatal(i)
+   }
+   if i := rep.Seek(rep.Duration()); i != -1 {
+      t.Fatal(i)
+   }
+}
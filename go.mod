@@ -5,6 +5,7 @@ go 1.18
 require (
 	github.com/edgeware/mp4ff v0.29.0
 	github.com/refraction-networking/utls v1.1.2
+	golang.org/x/net v0.1.0
 	google.golang.org/protobuf v1.28.1
 )
 
@@ -12,5 +13,6 @@ require (
 	github.com/andybalholm/brotli v1.0.4 // indirect
 	github.com/klauspost/compress v1.15.10 // indirect
 	golang.org/x/crypto v0.0.0-20220829220503-c86fa9a7ed90 // indirect
-	golang.org/x/sys v0.0.0-20220915200043-7b5979e65e41 // indirect
+	golang.org/x/sys v0.1.0 // indirect
+	golang.org/x/text v0.4.0 // indirect
 )
@@ -37,6 +37,7 @@ type Stream struct {
    Codecs string
    Resolution string
    Raw_URI string
+   Subtitles string
 }
 
 func (Medium) Ext() string {
@@ -76,8 +77,11 @@ func (m Stream) URI() string {
 }
 
 type Medium struct {
+   Autoselect bool
    Characteristics string
+   Default bool
    Group_ID string
+   Language string
    Name string
    Raw_URI string
    Type string
@@ -214,14 +218,26 @@ func (s Scanner) Master() (*Master, error) {
          var med Medium
          for s.Scan() != scanner.EOF {
             switch s.TokenText() {
+            case "AUTOSELECT":
+               s.Scan()
+               s.Scan()
+               med.Autoselect = s.TokenText() == "YES"
             case "CHARACTERISTICS":
                s.Scan()
                s.Scan()
                med.Characteristics, err = strconv.Unquote(s.TokenText())
+            case "DEFAULT":
+               s.Scan()
+               s.Scan()
+               med.Default = s.TokenText() == "YES"
             case "GROUP-ID":
                s.Scan()
                s.Scan()
                med.Group_ID, err = strconv.Unquote(s.TokenText())
+            case "LANGUAGE":
+               s.Scan()
+               s.Scan()
+               med.Language, err = strconv.Unquote(s.TokenText())
             case "NAME":
                s.Scan()
                s.Scan()
@@ -260,6 +276,10 @@ func (s Scanner) Master() (*Master, error) {
                s.Scan()
                s.Scan()
                str.Resolution = s.TokenText()
+            case "SUBTITLES":
+               s.Scan()
+               s.Scan()
+               str.Subtitles, err = strconv.Unquote(s.TokenText())
             }
             if err != nil {
                return nil, err
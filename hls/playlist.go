@@ -0,0 +1,136 @@
+package hls
+
+import (
+   "strconv"
+   "strings"
+   "time"
+)
+
+type Byte_Range struct {
+   Length int64
+   Offset int64
+}
+
+func (b Byte_Range) String() string {
+   var c []byte
+   c = strconv.AppendInt(c, b.Length, 10)
+   c = append(c, '@')
+   c = strconv.AppendInt(c, b.Offset, 10)
+   return string(c)
+}
+
+// media segment
+type Item struct {
+   Duration time.Duration
+   Range *Byte_Range
+   URI string
+}
+
+// VOD media playlist
+type Playlist struct {
+   Items []Item
+   Map string
+   Map_Range *Byte_Range
+}
+
+func (p Playlist) Target_Duration() int64 {
+   var carry time.Duration
+   for _, item := range p.Items {
+      if item.Duration > carry {
+         carry = item.Duration
+      }
+   }
+   // EXTINF rounded to the nearest integer must not exceed this value
+   return int64((carry + time.Second / 2) / time.Second)
+}
+
+func (p Playlist) M3U8() string {
+   var b []byte
+   b = append(b, "#EXTM3U\n"...)
+   // EXT-X-MAP in a playlist without EXT-X-I-FRAMES-ONLY
+   b = append(b, "#EXT-X-VERSION:6\n"...)
+   b = append(b, "#EXT-X-TARGETDURATION:"...)
+   b = strconv.AppendInt(b, p.Target_Duration(), 10)
+   b = append(b, "\n#EXT-X-PLAYLIST-TYPE:VOD\n"...)
+   if p.Map != "" {
+      b = append(b, "#EXT-X-MAP:URI="...)
+      b = strconv.AppendQuote(b, p.Map)
+      if p.Map_Range != nil {
+         b = append(b, ",BYTERANGE="...)
+         b = strconv.AppendQuote(b, p.Map_Range.String())
+      }
+      b = append(b, '\n')
+   }
+   for _, item := range p.Items {
+      b = append(b, "#EXTINF:"...)
+      b = strconv.AppendFloat(b, item.Duration.Seconds(), 'f', 3, 64)
+      b = append(b, ",\n"...)
+      if item.Range != nil {
+         b = append(b, "#EXT-X-BYTERANGE:"...)
+         b = append(b, item.Range.String()...)
+         b = append(b, '\n')
+      }
+      b = append(b, item.URI...)
+      b = append(b, '\n')
+   }
+   b = append(b, "#EXT-X-ENDLIST\n"...)
+   return string(b)
+}
+
+func (m Master) M3U8() string {
+   var b strings.Builder
+   b.WriteString("#EXTM3U\n")
+   b.WriteString("#EXT-X-VERSION:6\n")
+   b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+   for _, med := range m.Media {
+      b.WriteString("#EXT-X-MEDIA:TYPE=")
+      b.WriteString(med.Type)
+      b.WriteString(",GROUP-ID=")
+      b.WriteString(strconv.Quote(med.Group_ID))
+      b.WriteString(",NAME=")
+      b.WriteString(strconv.Quote(med.Name))
+      if med.Language != "" {
+         b.WriteString(",LANGUAGE=")
+         b.WriteString(strconv.Quote(med.Language))
+      }
+      if med.Default {
+         b.WriteString(",DEFAULT=YES")
+      }
+      if med.Autoselect {
+         b.WriteString(",AUTOSELECT=YES")
+      }
+      if med.Characteristics != "" {
+         b.WriteString(",CHARACTERISTICS=")
+         b.WriteString(strconv.Quote(med.Characteristics))
+      }
+      if med.Raw_URI != "" {
+         b.WriteString(",URI=")
+         b.WriteString(strconv.Quote(med.Raw_URI))
+      }
+      b.WriteByte('\n')
+   }
+   for _, str := range m.Streams {
+      b.WriteString("#EXT-X-STREAM-INF:BANDWIDTH=")
+      b.WriteString(strconv.FormatInt(str.Bandwidth, 10))
+      if str.Codecs != "" {
+         b.WriteString(",CODECS=")
+         b.WriteString(strconv.Quote(str.Codecs))
+      }
+      if str.Resolution != "" {
+         b.WriteString(",RESOLUTION=")
+         b.WriteString(str.Resolution)
+      }
+      if str.Audio != "" {
+         b.WriteString(",AUDIO=")
+         b.WriteString(strconv.Quote(str.Audio))
+      }
+      if str.Subtitles != "" {
+         b.WriteString(",SUBTITLES=")
+         b.WriteString(strconv.Quote(str.Subtitles))
+      }
+      b.WriteByte('\n')
+      b.WriteString(str.Raw_URI)
+      b.WriteByte('\n')
+   }
+   return b.String()
+}
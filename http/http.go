@@ -17,6 +17,7 @@ type (
    Header = http.Header
    Request = http.Request
    Response = http.Response
+   RoundTripper = http.RoundTripper
    Transport = http.Transport
 )
 
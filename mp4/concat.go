@@ -0,0 +1,294 @@
+package mp4
+
+import (
+   "bytes"
+   "errors"
+   "github.com/edgeware/mp4ff/mp4"
+   "io"
+   "strconv"
+   "time"
+)
+
+// jump in the source tfdt of a track. Duration is positive for a gap, and
+// negative for an overlap. The output closes jumps between inputs, and keeps
+// jumps within an input, so that tracks stay in sync.
+type Discontinuity struct {
+   Duration time.Duration
+   Input int
+   Sequence uint32
+   Track uint32
+}
+
+func (d Discontinuity) String() string {
+   var b []byte
+   if d.Duration >= 0 {
+      b = append(b, "gap "...)
+      b = append(b, d.Duration.String()...)
+   } else {
+      b = append(b, "overlap "...)
+      b = append(b, (-d.Duration).String()...)
+   }
+   b = append(b, " input "...)
+   b = strconv.AppendInt(b, int64(d.Input), 10)
+   b = append(b, " track "...)
+   b = strconv.AppendUint(b, uint64(d.Track), 10)
+   b = append(b, " sequence "...)
+   b = strconv.AppendUint(b, uint64(d.Sequence), 10)
+   return string(b)
+}
+
+// per track state of the timeline
+type timeline struct {
+   next uint64 // output, end of the last fragment
+   source uint64 // expected source tfdt, from the previous fragment
+   started bool
+   offset int32 // composition offset of the first sample
+   timescale uint32
+}
+
+// each input is a fragmented file, with the init of the first input used for
+// the output. A later input with a different init is an error, but it can
+// leave the init out. Each input is moved by one offset on the movie
+// timescale, so that it starts where the input before ends, and the first
+// starts from zero. Sequence numbers run from one. With edit_list, each trak
+// gets an elst so that presentation starts with the first sample, rather
+// than after its composition offset.
+func Concat(
+   w io.Writer, edit_list bool, inputs ...io.Reader,
+) ([]Discontinuity, error) {
+   var (
+      dis []Discontinuity
+      init *mp4.InitSegment
+      movie uint32
+      segs []*mp4.MediaSegment
+      seq uint32
+   )
+   lines := make(map[uint32]*timeline)
+   trexs := make(map[uint32]*mp4.TrexBox)
+   for i, input := range inputs {
+      file, err := mp4.DecodeFile(input)
+      if err != nil {
+         return nil, err
+      }
+      if init == nil {
+         init = file.Init
+         if init == nil {
+            return nil, errors.New("init not found")
+         }
+         movie = init.Moov.Mvhd.Timescale
+         if movie == 0 {
+            return nil, errors.New("mvhd timescale is zero")
+         }
+         for _, trak := range init.Moov.Traks {
+            if trak.Mdia.Mdhd.Timescale == 0 {
+               return nil, errors.New("mdhd timescale is zero")
+            }
+            lines[trak.Tkhd.TrackID] = &timeline{
+               timescale: trak.Mdia.Mdhd.Timescale,
+            }
+         }
+         if init.Moov.Mvex != nil {
+            for _, trex := range init.Moov.Mvex.Trexs {
+               trexs[trex.TrackID] = trex
+            }
+         }
+      } else if file.Init != nil {
+         same, err := same_tracks(init, file.Init)
+         if err != nil {
+            return nil, err
+         }
+         if !same {
+            return nil, errors.New(
+               "input " + strconv.Itoa(i) + " init differs from the first",
+            )
+         }
+      }
+      start, err := input_start(file, lines, movie)
+      if err != nil {
+         return nil, err
+      }
+      // end of the longest track, rounded up so that no track overlaps
+      var end int64
+      for _, line := range lines {
+         next := int64(line.next)
+         stop := rescale(next, line.timescale, movie)
+         if rescale(stop, movie, line.timescale) < next {
+            stop++
+         }
+         if stop > end {
+            end = stop
+         }
+      }
+      for _, seg := range file.Segments {
+         for _, frag := range seg.Fragments {
+            seq++
+            // tfdt can change version, and so size. tfdt comes before
+            // senc in the traf, so saio moves by the change so far.
+            var grown int64
+            for _, traf := range frag.Moof.Trafs {
+               line := lines[traf.Tfhd.TrackID]
+               trex := trex_box(trexs, traf.Tfhd.TrackID)
+               samples, err := frag.GetFullSamples(trex)
+               if err != nil {
+                  return nil, err
+               }
+               source := traf.Tfdt.BaseMediaDecodeTime
+               if !line.started {
+                  line.started = true
+                  if len(samples) >= 1 {
+                     line.offset = samples[0].CompositionTimeOffset
+                  }
+               } else if source != line.source {
+                  jump := int64(source - line.source)
+                  dis = append(dis, Discontinuity{
+                     Duration: ticks(jump, line.timescale),
+                     Input: i,
+                     Sequence: seq,
+                     Track: traf.Tfhd.TrackID,
+                  })
+               }
+               var duration uint64
+               for _, sample := range samples {
+                  duration += uint64(sample.Dur)
+               }
+               line.source = source + duration
+               size := traf.Tfdt.Size()
+               traf.Tfdt.SetBaseMediaDecodeTime(uint64(
+                  int64(source) + rescale(end - start, movie, line.timescale),
+               ))
+               line.next = traf.Tfdt.BaseMediaDecodeTime + duration
+               grown += int64(traf.Tfdt.Size()) - int64(size)
+               if traf.Saio != nil {
+                  for j := range traf.Saio.Offset {
+                     traf.Saio.Offset[j] += grown
+                  }
+               }
+            }
+            frag.Moof.Mfhd.SequenceNumber = seq
+            for _, traf := range frag.Moof.Trafs {
+               for _, trun := range traf.Truns {
+                  trun.DataOffset += int32(grown)
+               }
+            }
+         }
+         // sizes can change
+         seg.Sidx = nil
+         segs = append(segs, seg)
+      }
+   }
+   if init == nil {
+      return nil, errors.New("no inputs")
+   }
+   if edit_list {
+      for _, trak := range init.Moov.Traks {
+         set_edit_list(trak, lines[trak.Tkhd.TrackID].offset)
+      }
+   }
+   if err := init.Encode(w); err != nil {
+      return nil, err
+   }
+   for _, seg := range segs {
+      err := seg.Encode(w)
+      if err != nil {
+         return nil, err
+      }
+   }
+   return dis, nil
+}
+
+// earliest tfdt of the input, on the movie timescale
+func input_start(
+   file *mp4.File, lines map[uint32]*timeline, movie uint32,
+) (int64, error) {
+   var (
+      start int64
+      started bool
+   )
+   for _, seg := range file.Segments {
+      for _, frag := range seg.Fragments {
+         for _, traf := range frag.Moof.Trafs {
+            line := lines[traf.Tfhd.TrackID]
+            if line == nil || traf.Tfdt == nil {
+               return 0, errors.New("traf without trak or tfdt")
+            }
+            value := rescale(
+               int64(traf.Tfdt.BaseMediaDecodeTime), line.timescale, movie,
+            )
+            if !started || value < start {
+               start = value
+               started = true
+            }
+         }
+      }
+   }
+   return start, nil
+}
+
+// tracks with the same ID, timescale and sample entries
+func same_tracks(a, b *mp4.InitSegment) (bool, error) {
+   if len(a.Moov.Traks) != len(b.Moov.Traks) {
+      return false, nil
+   }
+   for i, trak := range a.Moov.Traks {
+      other := b.Moov.Traks[i]
+      if trak.Tkhd.TrackID != other.Tkhd.TrackID {
+         return false, nil
+      }
+      if trak.Mdia.Mdhd.Timescale != other.Mdia.Mdhd.Timescale {
+         return false, nil
+      }
+      var x, y bytes.Buffer
+      if err := trak.Mdia.Minf.Stbl.Stsd.Encode(&x); err != nil {
+         return false, err
+      }
+      if err := other.Mdia.Minf.Stbl.Stsd.Encode(&y); err != nil {
+         return false, err
+      }
+      if !bytes.Equal(x.Bytes(), y.Bytes()) {
+         return false, nil
+      }
+   }
+   return true, nil
+}
+
+// value from one timescale to another, rounded toward zero, and overflow safe
+func rescale(value int64, from, to uint32) int64 {
+   whole := value / int64(from) * int64(to)
+   part := value % int64(from) * int64(to) / int64(from)
+   return whole + part
+}
+
+// overflow safe
+func ticks(value int64, timescale uint32) time.Duration {
+   scale := int64(timescale)
+   if scale == 0 {
+      return 0
+   }
+   whole := time.Duration(value / scale) * time.Second
+   part := time.Duration(value % scale) * time.Second / time.Duration(scale)
+   return whole + part
+}
+
+// segment_duration of zero means the whole media, as the duration is not
+// known for a fragmented file
+func set_edit_list(trak *mp4.TrakBox, offset int32) {
+   elst := &mp4.ElstBox{
+      Entries: []mp4.ElstEntry{
+         {MediaTime: int64(offset), MediaRateInteger: 1},
+      },
+   }
+   edts := new(mp4.EdtsBox)
+   edts.AddChild(elst)
+   var children []mp4.Box
+   for _, child := range trak.Children {
+      switch child.Type() {
+      case "edts":
+      case "tkhd":
+         children = append(children, child, edts)
+      default:
+         children = append(children, child)
+      }
+   }
+   trak.Children = children
+   trak.Edts = edts
+}
@@ -0,0 +1,142 @@
+package mp4
+
+import (
+   "bytes"
+   "github.com/edgeware/mp4ff/mp4"
+   "io"
+   "testing"
+)
+
+func Test_Concat(t *testing.T) {
+   init, segs, err := clear_fixture.encode()
+   if err != nil {
+      t.Fatal(err)
+   }
+   // both inputs start at zero, so the second overlaps the first
+   input := append(init.Bytes(), segs.Bytes()...)
+   var buf bytes.Buffer
+   dis, err := Concat(
+      &buf, true, bytes.NewReader(input), bytes.NewReader(input),
+   )
+   if err != nil {
+      t.Fatal(err)
+   }
+   if len(dis) != 1 {
+      t.Fatal(dis)
+   }
+   s := dis[0].String()
+   if s != "overlap 682.666666ms input 1 track 1 sequence 5" {
+      t.Fatal(s)
+   }
+   file, err := mp4.DecodeFile(bytes.NewReader(buf.Bytes()))
+   if err != nil {
+      t.Fatal(err)
+   }
+   if file.Moov.Trak.Edts == nil {
+      t.Fatal("edts")
+   }
+   var seq uint32
+   for _, seg := range file.Segments {
+      for _, frag := range seg.Fragments {
+         if frag.Moof.Mfhd.SequenceNumber != seq + 1 {
+            t.Fatal(frag.Moof.Mfhd)
+         }
+         if frag.Moof.Traf.Tfdt.BaseMediaDecodeTime != uint64(seq) * 8192 {
+            t.Fatal(frag.Moof.Traf.Tfdt)
+         }
+         seq++
+      }
+   }
+   if seq != 8 {
+      t.Fatal(seq)
+   }
+}
+
+func Test_Concat_Tracks(t *testing.T) {
+   audio_init, audio_segs, err := clear_fixture.encode()
+   if err != nil {
+      t.Fatal(err)
+   }
+   video_init, video_segs, err := video_fixture.encode()
+   if err != nil {
+      t.Fatal(err)
+   }
+   var input bytes.Buffer
+   err = Mux(
+      &input,
+      bytes.NewReader(append(audio_init.Bytes(), audio_segs.Bytes()...)),
+      bytes.NewReader(append(video_init.Bytes(), video_segs.Bytes()...)),
+   )
+   if err != nil {
+      t.Fatal(err)
+   }
+   var buf bytes.Buffer
+   _, err = Concat(
+      &buf, false, bytes.NewReader(input.Bytes()),
+      bytes.NewReader(input.Bytes()),
+   )
+   if err != nil {
+      t.Fatal(err)
+   }
+   file, err := mp4.DecodeFile(bytes.NewReader(buf.Bytes()))
+   if err != nil {
+      t.Fatal(err)
+   }
+   // first tfdt of each track in the second input
+   second := make(map[uint32]uint64)
+   for _, seg := range file.Segments {
+      for _, frag := range seg.Fragments {
+         traf := frag.Moof.Traf
+         if frag.Moof.Mfhd.SequenceNumber <= 6 {
+            continue
+         }
+         if _, ok := second[traf.Tfhd.TrackID]; !ok {
+            second[traf.Tfhd.TrackID] = traf.Tfdt.BaseMediaDecodeTime
+         }
+      }
+   }
+   // the audio is longer, so both start after it
+   if second[1] < 32768 || second[1] * 90000 != second[2] * 48000 {
+      t.Fatal(second)
+   }
+}
+
+func Test_Concat_Init(t *testing.T) {
+   var inputs []io.Reader
+   for _, fix := range []fixture{clear_fixture, video_fixture} {
+      init, segs, err := fix.encode()
+      if err != nil {
+         t.Fatal(err)
+      }
+      inputs = append(inputs, io.MultiReader(init, segs))
+   }
+   if _, err := Concat(io.Discard, false, inputs...); err == nil {
+      t.Fatal("no error")
+   }
+}
+
+// tfdt goes from version 1 to version 0, so the senc moves
+func Test_Concat_Saio(t *testing.T) {
+   key := bytes.Repeat([]byte{8}, 16)
+   late := clear_fixture
+   late.start = 1 << 32
+   _, protected, err := late.encrypt("cenc", key)
+   if err != nil {
+      t.Fatal(err)
+   }
+   var buf bytes.Buffer
+   if _, err := Concat(&buf, false, bytes.NewReader(protected)); err != nil {
+      t.Fatal(err)
+   }
+   var dec bytes.Buffer
+   if err := decrypt_file(&dec, buf.Bytes(), key); err != nil {
+      t.Fatal(err)
+   }
+   clear, _, err := clear_fixture.encrypt("cenc", key)
+   if err != nil {
+      t.Fatal(err)
+   }
+   if !bytes.Equal(dec.Bytes(), clear) {
+      t.Fatal(dec.Len(), len(clear))
+   }
+}
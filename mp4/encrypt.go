@@ -0,0 +1,375 @@
+package mp4
+
+import (
+   "crypto/aes"
+   "crypto/cipher"
+   "crypto/rand"
+   "errors"
+   "github.com/edgeware/mp4ff/mp4"
+   "io"
+)
+
+// the inverse of Decrypt, for making protected test assets
+type Encrypt struct {
+   iv []byte
+   key []byte
+   kid []byte
+   // NAL length size of each video track
+   nal map[uint32]int
+   pssh []*mp4.PsshBox
+   scheme string
+   sinf map[uint32]*mp4.SinfBox
+   trex map[uint32]*mp4.TrexBox
+   write io.Writer
+}
+
+// scheme is cenc or cbcs. cbcs uses a random constant IV, and cenc a
+// random 8 byte IV for each sample.
+func New_Encrypt(
+   w io.Writer, scheme string, kid, key []byte,
+) (Encrypt, error) {
+   var enc Encrypt
+   switch scheme {
+   case "cenc":
+   case "cbcs":
+      enc.iv = make([]byte, 16)
+      if _, err := rand.Read(enc.iv); err != nil {
+         return Encrypt{}, err
+      }
+   default:
+      return Encrypt{}, Scheme_Error{scheme}
+   }
+   if len(kid) != 16 {
+      return Encrypt{}, errors.New("KID must be 16 bytes")
+   }
+   if _, err := aes.NewCipher(key); err != nil {
+      return Encrypt{}, err
+   }
+   enc.key = key
+   enc.kid = kid
+   enc.nal = make(map[uint32]int)
+   enc.scheme = scheme
+   enc.sinf = make(map[uint32]*mp4.SinfBox)
+   enc.trex = make(map[uint32]*mp4.TrexBox)
+   enc.write = w
+   return enc, nil
+}
+
+// call before Init. The PSSH lists the KID, and data is the system specific
+// payload, which can be empty.
+func (e *Encrypt) Add_PSSH(system_id, data []byte) {
+   e.pssh = append(e.pssh, &mp4.PsshBox{
+      Version: 1,
+      SystemID: system_id,
+      KIDs: []mp4.UUID{e.kid},
+      Data: data,
+   })
+}
+
+// audio and video sample entries become enca and encv
+func (e Encrypt) Init(r io.Reader) error {
+   file, err := mp4.DecodeFile(r)
+   if err != nil {
+      return err
+   }
+   if file.Init.Moov.Mvex != nil {
+      for _, trex := range file.Init.Moov.Mvex.Trexs {
+         e.trex[trex.TrackID] = trex
+      }
+   }
+   for _, trak := range file.Init.Moov.Traks {
+      stsd := trak.Mdia.Minf.Stbl.Stsd
+      for i, child := range stsd.Children {
+         switch box := child.(type) {
+         case *mp4.AudioSampleEntryBox:
+            sinf, err := e.new_sinf(box.Type(), false)
+            if err != nil {
+               return err
+            }
+            // AudioSampleEntryBox has no SetType
+            enca := mp4.CreateAudioSampleEntryBox(
+               "enca", box.ChannelCount, box.SampleSize, box.SampleRate, nil,
+            )
+            enca.DataReferenceIndex = box.DataReferenceIndex
+            for _, child := range box.Children {
+               enca.AddChild(child)
+            }
+            enca.AddChild(sinf)
+            stsd.Children[i] = enca
+            e.sinf[trak.Tkhd.TrackID] = sinf
+         case *mp4.VisualSampleEntryBox:
+            sinf, err := e.new_sinf(box.Type(), true)
+            if err != nil {
+               return err
+            }
+            box.SetType("encv")
+            box.AddChild(sinf)
+            e.nal[trak.Tkhd.TrackID] = nal_length_size(box)
+            e.sinf[trak.Tkhd.TrackID] = sinf
+         }
+      }
+   }
+   for _, pssh := range e.pssh {
+      file.Init.Moov.AddChild(pssh)
+   }
+   return file.Init.Encode(e.write)
+}
+
+func (e Encrypt) new_sinf(format string, video bool) (*mp4.SinfBox, error) {
+   if format == "enca" || format == "encv" {
+      return nil, errors.New("track is already encrypted")
+   }
+   tenc := mp4.TencBox{DefaultIsProtected: 1, DefaultKID: e.kid}
+   switch e.scheme {
+   case "cenc":
+      tenc.DefaultPerSampleIVSize = 8
+   case "cbcs":
+      tenc.Version = 1
+      tenc.DefaultConstantIV = e.iv
+      // audio is whole sample, as with Apple HLS
+      if video {
+         tenc.DefaultCryptByteBlock = 1
+         tenc.DefaultSkipByteBlock = 9
+      }
+   }
+   schi := new(mp4.SchiBox)
+   schi.AddChild(&tenc)
+   sinf := new(mp4.SinfBox)
+   sinf.AddChild(&mp4.FrmaBox{DataFormat: format})
+   sinf.AddChild(&mp4.SchmBox{SchemeType: e.scheme, SchemeVersion: 0x10000})
+   sinf.AddChild(schi)
+   return sinf, nil
+}
+
+func (e Encrypt) Segment(r io.Reader) error {
+   file, err := mp4.DecodeFile(r)
+   if err != nil {
+      return err
+   }
+   for _, seg := range file.Segments {
+      for _, frag := range seg.Fragments {
+         err := e.fragment(frag)
+         if err != nil {
+            return err
+         }
+      }
+      // sizes have changed
+      seg.Sidx = nil
+      err := seg.Encode(e.write)
+      if err != nil {
+         return err
+      }
+   }
+   return nil
+}
+
+func (e Encrypt) fragment(frag *mp4.Fragment) error {
+   var added uint64
+   for _, traf := range frag.Moof.Trafs {
+      sinf := e.sinf[traf.Tfhd.TrackID]
+      if sinf == nil {
+         continue
+      }
+      trex := trex_box(e.trex, traf.Tfhd.TrackID)
+      samples, err := frag.GetFullSamples(trex)
+      if err != nil {
+         return err
+      }
+      var (
+         info []byte
+         senc = mp4.CreateSencBox()
+      )
+      for _, sample := range samples {
+         var iv []byte
+         if e.scheme == "cenc" {
+            iv = make([]byte, 8)
+            if _, err := rand.Read(iv); err != nil {
+               return err
+            }
+         }
+         sub := e.sub_samples(
+            sinf.Frma.DataFormat, e.nal[traf.Tfhd.TrackID], sample.Data,
+         )
+         err := e.encrypt_sample(sample.Data, iv, sub, sinf.Schi.Tenc)
+         if err != nil {
+            return err
+         }
+         err = senc.AddSample(mp4.SencSample{IV: iv, SubSamples: sub})
+         if err != nil {
+            return err
+         }
+         size := len(iv)
+         if sub != nil {
+            size += 2 + 6 * len(sub)
+         }
+         info = append(info, byte(size))
+      }
+      saiz := &mp4.SaizBox{SampleCount: uint32(len(info))}
+      for _, size := range info {
+         if size != info[0] {
+            saiz.SampleInfo = info
+            break
+         }
+      }
+      if saiz.SampleInfo == nil && len(info) >= 1 {
+         // cbcs audio has constant IV and no subsamples, so nothing to store
+         if info[0] == 0 {
+            continue
+         }
+         saiz.DefaultSampleInfoSize = info[0]
+      }
+      // offset is set once every traf has its boxes
+      saio := &mp4.SaioBox{Offset: []int64{0}}
+      for _, box := range []mp4.Box{saiz, saio, senc} {
+         traf.AddChild(box)
+         added += box.Size()
+      }
+   }
+   for _, traf := range frag.Moof.Trafs {
+      for _, trun := range traf.Truns {
+         // required for playback
+         trun.DataOffset += int32(added)
+      }
+   }
+   set_saio(frag.Moof)
+   return nil
+}
+
+// saio is the offset from the start of the moof to the senc data
+func set_saio(moof *mp4.MoofBox) {
+   // box header
+   pos := uint64(8)
+   for _, child := range moof.Children {
+      traf, ok := child.(*mp4.TrafBox)
+      if ok && traf.Saio != nil {
+         start := pos + 8
+         for _, box := range traf.Children {
+            if box == traf.Senc {
+               // box header, version and flags, sample count
+               traf.Saio.Offset[0] = int64(start + 16)
+            }
+            start += box.Size()
+         }
+      }
+      pos += child.Size()
+   }
+}
+
+// from lengthSizeMinusOne in the avcC or hvcC
+func nal_length_size(entry *mp4.VisualSampleEntryBox) int {
+   if entry.HvcC != nil {
+      return int(entry.HvcC.LengthSizeMinusOne) + 1
+   }
+   // mp4ff has no field for it in avcC, and fails to decode an avcC that
+   // is not 4 bytes
+   return 4
+}
+
+// big endian NAL length of size bytes, at the start of sample
+func nal_length(sample []byte, size int) int {
+   var length int
+   for _, b := range sample[:size] {
+      length = length << 8 | int(b)
+   }
+   return length
+}
+
+// subsamples keep the NAL length and header clear, and only slices are
+// protected. Other formats are protected as whole samples. length is the
+// size of each NAL length.
+func (e Encrypt) sub_samples(
+   format string, length int, sample []byte,
+) []mp4.SubSamplePattern {
+   var header int
+   switch format {
+   case "avc1", "avc3":
+      header = 1
+   case "hev1", "hvc1":
+      header = 2
+   default:
+      return nil
+   }
+   var (
+      clear int
+      sub []mp4.SubSamplePattern
+   )
+   add := func(protected int) {
+      for clear > 0xFFFF {
+         sub = append(sub, mp4.SubSamplePattern{BytesOfClearData: 0xFFFF})
+         clear -= 0xFFFF
+      }
+      sub = append(sub, mp4.SubSamplePattern{
+         BytesOfClearData: uint16(clear),
+         BytesOfProtectedData: uint32(protected),
+      })
+      clear = 0
+   }
+   for len(sample) > length {
+      size := length + nal_length(sample, length)
+      if size > len(sample) {
+         break
+      }
+      var protected int
+      if slice(format, sample[length]) {
+         protected = size - length - header
+         if e.scheme == "cenc" {
+            // CTR for video is block aligned
+            protected &^= 0xF
+         }
+         if protected < 0 {
+            protected = 0
+         }
+      }
+      clear += size - protected
+      if protected >= 1 {
+         add(protected)
+      }
+      sample = sample[size:]
+   }
+   clear += len(sample)
+   if clear >= 1 || sub == nil {
+      add(0)
+   }
+   return sub
+}
+
+func slice(format string, header byte) bool {
+   switch format {
+   case "avc1", "avc3":
+      kind := header & 0x1F
+      return kind >= 1 && kind <= 5
+   }
+   kind := header >> 1 & 0x3F
+   return kind <= 31
+}
+
+func (e Encrypt) encrypt_sample(
+   sample, iv []byte, sub []mp4.SubSamplePattern, tenc *mp4.TencBox,
+) error {
+   block, err := aes.NewCipher(e.key)
+   if err != nil {
+      return err
+   }
+   ranges, err := protected_ranges(sample, sub)
+   if err != nil {
+      return err
+   }
+   if e.scheme == "cenc" {
+      iv = append(iv, 0, 0, 0, 0, 0, 0, 0, 0)
+      stream := cipher.NewCTR(block, iv)
+      for _, data := range ranges {
+         stream.XORKeyStream(data, data)
+      }
+      return nil
+   }
+   crypt, skip := tenc.DefaultCryptByteBlock, tenc.DefaultSkipByteBlock
+   // cbcs starts the chain again with each subsample
+   for _, data := range ranges {
+      mode := cipher.NewCBCEncrypter(block, tenc.DefaultConstantIV)
+      pattern(data, crypt, skip, func(b []byte) {
+         b = b[:len(b) &^ 0xF]
+         mode.CryptBlocks(b, b)
+      })
+   }
+   return nil
+}
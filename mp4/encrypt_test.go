@@ -0,0 +1,135 @@
+package mp4
+
+import (
+   "bytes"
+   "io"
+   "testing"
+)
+
+// SPS, then an IDR slice, with 4 byte NAL lengths
+var avc_fixture = func() fixture {
+   f := video_fixture
+   f.data = func(seq, sample int) []byte {
+      data := []byte{0, 0, 0, 4, 0x67, 1, 2, 3, 0, 0, 0, 101, 0x65}
+      return append(data, bytes.Repeat([]byte{byte(seq), byte(sample)}, 50)...)
+   }
+   return f
+}()
+
+func Test_Encrypt(t *testing.T) {
+   key := bytes.Repeat([]byte{4}, 16)
+   for _, fix := range []fixture{clear_fixture, avc_fixture} {
+      for _, scheme := range []string{"cenc", "cbcs"} {
+         clear, protected, err := fix.encrypt(scheme, key)
+         if err != nil {
+            t.Fatal(err)
+         }
+         if bytes.Contains(protected, clear[len(clear)-100:]) {
+            t.Fatal(fix.handler, scheme, "not encrypted")
+         }
+         pro, err := Inspect(bytes.NewReader(protected))
+         if err != nil {
+            t.Fatal(err)
+         }
+         if pro.Tracks[0].Scheme != scheme || len(pro.PSSH) != 1 {
+            t.Fatal(pro)
+         }
+         var dec bytes.Buffer
+         if err := decrypt_file(&dec, protected, key); err != nil {
+            t.Fatal(fix.handler, scheme, err)
+         }
+         if !bytes.Equal(dec.Bytes(), clear) {
+            t.Fatal(fix.handler, scheme, "round trip")
+         }
+      }
+   }
+}
+
+func decrypt_file(w io.Writer, file, key []byte) error {
+   dec := New_Decrypt(w)
+   if err := dec.Init(bytes.NewReader(file)); err != nil {
+      return err
+   }
+   return dec.Segment(bytes.NewReader(file), key)
+}
+
+var widevine_id = []byte{
+   0xed, 0xef, 0x8b, 0xa9, 0x79, 0xd6, 0x4a, 0xce,
+   0xa3, 0xc8, 0x27, 0xdc, 0xd5, 0x1d, 0x21, 0xed,
+}
+
+// big endian length of size bytes, then the NAL
+func length_nal(size int, nal []byte) []byte {
+   length := make([]byte, size)
+   for i, n := size - 1, len(nal); i >= 0; i-- {
+      length[i] = byte(n)
+      n >>= 8
+   }
+   return append(length, nal...)
+}
+
+func Test_Sub_Samples(t *testing.T) {
+   for _, size := range []int{1, 2, 4} {
+      // SPS, then an IDR slice of 100 bytes
+      sample := length_nal(size, []byte{0x67, 1, 2, 3})
+      slice := append([]byte{0x65}, make([]byte, 99)...)
+      sample = append(sample, length_nal(size, slice)...)
+      for _, scheme := range []string{"cenc", "cbcs"} {
+         sub_sample(t, scheme, size, sample)
+      }
+   }
+}
+
+func sub_sample(t *testing.T, scheme string, size int, sample []byte) {
+   enc, err := New_Encrypt(nil, scheme, make([]byte, 16), make([]byte, 16))
+   if err != nil {
+      t.Fatal(err)
+   }
+   sub := enc.sub_samples("avc1", size, sample)
+   if len(sub) != 1 {
+      t.Fatal(size, sub)
+   }
+   protected := sub[0].BytesOfProtectedData
+   if int(sub[0].BytesOfClearData) + int(protected) != len(sample) {
+      t.Fatal(size, sub)
+   }
+   switch scheme {
+   case "cenc":
+      if protected != 96 {
+         t.Fatal(size, sub)
+      }
+   case "cbcs":
+      if protected != 99 {
+         t.Fatal(size, sub)
+      }
+   }
+   sinf, err := enc.new_sinf("avc1", true)
+   if err != nil {
+      t.Fatal(err)
+   }
+   iv := make([]byte, 8)
+   data := append([]byte{}, sample...)
+   err = enc.encrypt_sample(data, iv, sub, sinf.Schi.Tenc)
+   if err != nil {
+      t.Fatal(err)
+   }
+   if bytes.Equal(data, sample) {
+      t.Fatal(scheme, "not encrypted")
+   }
+   tenc := sinf.Schi.Tenc
+   prot := protection{
+      crypt: tenc.DefaultCryptByteBlock, skip: tenc.DefaultSkipByteBlock,
+   }
+   if scheme == "cbcs" {
+      iv = tenc.DefaultConstantIV
+   } else {
+      iv = make([]byte, 16)
+   }
+   err = decrypt_sample(scheme, data, enc.key, iv, sub, prot)
+   if err != nil {
+      t.Fatal(err)
+   }
+   if !bytes.Equal(data, sample) {
+      t.Fatal(scheme, "round trip")
+   }
+}
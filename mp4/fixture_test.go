@@ -0,0 +1,132 @@
+package mp4
+
+import (
+   "bytes"
+   "github.com/edgeware/mp4ff/avc"
+   "github.com/edgeware/mp4ff/mp4"
+)
+
+// single track fragmented file
+type fixture struct {
+   // audio is AAC, and video is AVC with 4 byte NAL lengths
+   handler string
+   timescale uint32
+   // of each sample
+   duration uint32
+   segments int
+   // in each segment
+   fragments int
+   // in each fragment
+   samples int
+   // by fragment sequence number from 1, and sample from 0
+   data func(seq, sample int) []byte
+   // decode time of the first sample
+   start uint64
+}
+
+// AAC track, with two segments of two fragments each
+var clear_fixture = fixture{
+   handler: "audio",
+   timescale: 48000,
+   duration: 1024,
+   segments: 2,
+   fragments: 2,
+   samples: 8,
+   data: func(seq, sample int) []byte {
+      return bytes.Repeat([]byte{byte(seq), byte(sample)}, 100 + sample)
+   },
+}
+
+// 90 kHz track, with two fragments of 0.25 seconds
+var video_fixture = fixture{
+   handler: "video",
+   timescale: 90000,
+   duration: 3750,
+   segments: 2,
+   fragments: 1,
+   samples: 6,
+   data: func(int, int) []byte {
+      return make([]byte, 10)
+   },
+}
+
+func (f fixture) encode() (*bytes.Buffer, *bytes.Buffer, error) {
+   init := mp4.CreateEmptyInit()
+   lang := "und"
+   if f.handler == "audio" {
+      lang = "en"
+   }
+   init.AddEmptyTrack(f.timescale, f.handler, lang)
+   trak := init.Moov.Trak
+   if f.handler == "audio" {
+      err := trak.SetAACDescriptor(2, int(f.timescale))
+      if err != nil {
+         return nil, nil, err
+      }
+   } else {
+      // the SPS is not parsed, so it only has to look like one
+      avcc := mp4.AvcCBox{DecConfRec: avc.DecConfRec{
+         AVCProfileIndication: 66,
+         AVCLevelIndication: 30,
+         SPSnalus: [][]byte{{0x67, 0x42, 0xC0, 0x1E}},
+         PPSnalus: [][]byte{{0x68, 0xCE, 0x3C, 0x80}},
+      }}
+      trak.Mdia.Minf.Stbl.Stsd.AddChild(
+         mp4.CreateVisualSampleEntryBox("avc1", 640, 360, &avcc),
+      )
+   }
+   dec := f.start
+   var seq int
+   segs := new(bytes.Buffer)
+   for i := 0; i < f.segments; i++ {
+      seg := mp4.NewMediaSegment()
+      for j := 0; j < f.fragments; j++ {
+         seq++
+         frag, err := mp4.CreateFragment(uint32(seq), 1)
+         if err != nil {
+            return nil, nil, err
+         }
+         for k := 0; k < f.samples; k++ {
+            data := f.data(seq, k)
+            frag.AddFullSample(mp4.FullSample{
+               Sample: mp4.Sample{
+                  Dur: f.duration, Flags: 0x2000000, Size: uint32(len(data)),
+               },
+               DecodeTime: dec,
+               Data: data,
+            })
+            dec += uint64(f.duration)
+         }
+         seg.AddFragment(frag)
+      }
+      if err := seg.Encode(segs); err != nil {
+         return nil, nil, err
+      }
+   }
+   buf := new(bytes.Buffer)
+   if err := init.Encode(buf); err != nil {
+      return nil, nil, err
+   }
+   return buf, segs, nil
+}
+
+// init and segments as one file, both clear and protected
+func (f fixture) encrypt(scheme string, key []byte) ([]byte, []byte, error) {
+   init, segs, err := f.encode()
+   if err != nil {
+      return nil, nil, err
+   }
+   var protected bytes.Buffer
+   enc, err := New_Encrypt(&protected, scheme, make([]byte, 16), key)
+   if err != nil {
+      return nil, nil, err
+   }
+   enc.Add_PSSH(widevine_id, nil)
+   if err := enc.Init(bytes.NewReader(init.Bytes())); err != nil {
+      return nil, nil, err
+   }
+   if err := enc.Segment(bytes.NewReader(segs.Bytes())); err != nil {
+      return nil, nil, err
+   }
+   return append(init.Bytes(), segs.Bytes()...), protected.Bytes(), nil
+}
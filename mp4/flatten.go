@@ -0,0 +1,241 @@
+package mp4
+
+import (
+   "errors"
+   "github.com/edgeware/mp4ff/mp4"
+   "io"
+   "math"
+)
+
+// samples of one traf, which become one chunk
+type chunk struct {
+   samples []mp4.FullSample
+   track int
+}
+
+// r is a fragmented file, init followed by segments, such as the output of
+// Decrypt. w gets a progressive file, with the moov before the mdat.
+func Flatten(w io.Writer, r io.Reader) error {
+   file, err := mp4.DecodeFile(r)
+   if err != nil {
+      return err
+   }
+   if file.Init == nil {
+      return errors.New("init not found")
+   }
+   moov := file.Init.Moov
+   trexs := make(map[uint32]*mp4.TrexBox)
+   if moov.Mvex != nil {
+      for _, trex := range moov.Mvex.Trexs {
+         trexs[trex.TrackID] = trex
+      }
+   }
+   tracks := make(map[uint32]int)
+   for i, trak := range moov.Traks {
+      tracks[trak.Tkhd.TrackID] = i
+   }
+   // chunks are interleaved in the order of the fragments
+   var chunks []chunk
+   for _, seg := range file.Segments {
+      for _, frag := range seg.Fragments {
+         for _, traf := range frag.Moof.Trafs {
+            track, ok := tracks[traf.Tfhd.TrackID]
+            if !ok {
+               return errors.New("traf without trak")
+            }
+            trex := trex_box(trexs, traf.Tfhd.TrackID)
+            samples, err := frag.GetFullSamples(trex)
+            if err != nil {
+               return err
+            }
+            if len(samples) >= 1 {
+               chunks = append(chunks, chunk{samples, track})
+            }
+         }
+      }
+   }
+   mdat := new(mp4.MdatBox)
+   offsets := make([][]uint64, len(moov.Traks))
+   for _, c := range chunks {
+      offsets[c.track] = append(offsets[c.track], mdat.DataLength())
+      for _, sample := range c.samples {
+         mdat.AddSampleData(sample.Data)
+      }
+   }
+   for i, trak := range moov.Traks {
+      set_tables(trak, chunks, i)
+   }
+   if err := set_durations(moov); err != nil {
+      return err
+   }
+   remove_mvex(moov)
+   ftyp := mp4.NewFtyp("isom", 0x200, []string{"isom", "iso2", "mp41"})
+   // moov size depends on stco or co64, so try stco first
+   large := false
+   for {
+      for i, trak := range moov.Traks {
+         set_offsets(trak.Mdia.Minf.Stbl, offsets[i], 0, large)
+      }
+      base := ftyp.Size() + moov.Size() + mdat.HeaderSize()
+      if large || base + mdat.DataLength() <= math.MaxUint32 {
+         for i, trak := range moov.Traks {
+            set_offsets(trak.Mdia.Minf.Stbl, offsets[i], base, large)
+         }
+         break
+      }
+      large = true
+   }
+   for _, box := range []mp4.Box{ftyp, moov, mdat} {
+      err := box.Encode(w)
+      if err != nil {
+         return err
+      }
+   }
+   return nil
+}
+
+// replace the empty tables from the init with ones for the samples
+func set_tables(trak *mp4.TrakBox, chunks []chunk, track int) {
+   var (
+      ctts mp4.CttsBox
+      stsc mp4.StscBox
+      stss mp4.StssBox
+      stsz mp4.StszBox
+      stts mp4.SttsBox
+   )
+   var (
+      chunk_number uint32
+      sample_number uint32
+      sync = true
+   )
+   for _, c := range chunks {
+      if c.track != track {
+         continue
+      }
+      chunk_number++
+      count := uint32(len(c.samples))
+      last := len(stsc.SamplesPerChunk) - 1
+      if last < 0 || stsc.SamplesPerChunk[last] != count {
+         stsc.FirstChunk = append(stsc.FirstChunk, chunk_number)
+         stsc.SamplesPerChunk = append(stsc.SamplesPerChunk, count)
+      }
+      for _, sample := range c.samples {
+         sample_number++
+         add_run(&stts.SampleCount, &stts.SampleTimeDelta, sample.Dur)
+         offset := sample.CompositionTimeOffset
+         last := len(ctts.SampleOffset) - 1
+         if last < 0 || ctts.SampleOffset[last] != offset {
+            ctts.SampleCount = append(ctts.SampleCount, 1)
+            ctts.SampleOffset = append(ctts.SampleOffset, offset)
+         } else {
+            ctts.SampleCount[last]++
+         }
+         if offset < 0 {
+            ctts.Version = 1
+         }
+         if sample.IsSync() {
+            stss.SampleNumber = append(stss.SampleNumber, sample_number)
+         } else {
+            sync = false
+         }
+         stsz.SampleSize = append(stsz.SampleSize, sample.Size)
+      }
+   }
+   stsc.SetSingleSampleDescriptionID(1)
+   stsz.SampleNumber = sample_number
+   stbl := trak.Mdia.Minf.Stbl
+   var children []mp4.Box
+   for _, child := range stbl.Children {
+      switch child.Type() {
+      case "co64", "ctts", "stco", "stsc", "stss", "stsz", "stts":
+      default:
+         children = append(children, child)
+      }
+   }
+   stbl.Children = children
+   stbl.Co64, stbl.Ctts, stbl.Stco, stbl.Stss = nil, nil, nil, nil
+   stbl.AddChild(&stts)
+   // no ctts if every offset is zero
+   if len(ctts.SampleOffset) >= 2 ||
+      len(ctts.SampleOffset) == 1 && ctts.SampleOffset[0] != 0 {
+      stbl.AddChild(&ctts)
+   }
+   stbl.AddChild(&stsc)
+   stbl.AddChild(&stsz)
+   // no stss if every sample is sync
+   if !sync {
+      stbl.AddChild(&stss)
+   }
+}
+
+func add_run(counts, values *[]uint32, value uint32) {
+   last := len(*values) - 1
+   if last >= 0 && (*values)[last] == value {
+      (*counts)[last]++
+      return
+   }
+   *counts = append(*counts, 1)
+   *values = append(*values, value)
+}
+
+// base is the file offset of the mdat data
+func set_offsets(stbl *mp4.StblBox, offsets []uint64, base uint64, large bool) {
+   var children []mp4.Box
+   for _, child := range stbl.Children {
+      switch child.Type() {
+      case "co64", "stco":
+      default:
+         children = append(children, child)
+      }
+   }
+   stbl.Children = children
+   stbl.Co64, stbl.Stco = nil, nil
+   if large {
+      var co64 mp4.Co64Box
+      for _, offset := range offsets {
+         co64.ChunkOffset = append(co64.ChunkOffset, base + offset)
+      }
+      stbl.AddChild(&co64)
+   } else {
+      var stco mp4.StcoBox
+      for _, offset := range offsets {
+         stco.ChunkOffset = append(stco.ChunkOffset, uint32(base + offset))
+      }
+      stbl.AddChild(&stco)
+   }
+}
+
+// in a fragmented file these are zero, as the samples are in the fragments
+func set_durations(moov *mp4.MoovBox) error {
+   moov.Mvhd.Duration = 0
+   for _, trak := range moov.Traks {
+      var duration uint64
+      stts := trak.Mdia.Minf.Stbl.Stts
+      for i, count := range stts.SampleCount {
+         duration += uint64(count) * uint64(stts.SampleTimeDelta[i])
+      }
+      mdhd := trak.Mdia.Mdhd
+      if mdhd.Timescale == 0 {
+         return errors.New("mdhd timescale is zero")
+      }
+      mdhd.Duration = duration
+      // tkhd is in the mvhd timescale
+      trak.Tkhd.Duration = duration * uint64(moov.Mvhd.Timescale) /
+         uint64(mdhd.Timescale)
+      if trak.Tkhd.Duration > moov.Mvhd.Duration {
+         moov.Mvhd.Duration = trak.Tkhd.Duration
+      }
+   }
+   return nil
+}
+
+func remove_mvex(moov *mp4.MoovBox) {
+   var children []mp4.Box
+   for _, child := range moov.Children {
+      if child.Type() != "mvex" {
+         children = append(children, child)
+      }
+   }
+   moov.Children = children
+   moov.Mvex = nil
+}
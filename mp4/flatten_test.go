@@ -0,0 +1,93 @@
+package mp4
+
+import (
+   "bytes"
+   "github.com/edgeware/mp4ff/mp4"
+   "io"
+   "testing"
+)
+
+func Test_Flatten(t *testing.T) {
+   init, segs, err := clear_fixture.encode()
+   if err != nil {
+      t.Fatal(err)
+   }
+   var buf bytes.Buffer
+   if err := Flatten(&buf, io.MultiReader(init, segs)); err != nil {
+      t.Fatal(err)
+   }
+   file, err := mp4.DecodeFile(bytes.NewReader(buf.Bytes()))
+   if err != nil {
+      t.Fatal(err)
+   }
+   var types []string
+   for _, box := range file.Children {
+      types = append(types, box.Type())
+   }
+   if len(types) != 3 || types[1] != "moov" || types[2] != "mdat" {
+      t.Fatal(types)
+   }
+   if file.Moov.Mvex != nil {
+      t.Fatal("mvex")
+   }
+   trak := file.Moov.Trak
+   if n := trak.GetNrSamples(); n != 32 {
+      t.Fatal(n)
+   }
+   if d := trak.Mdia.Mdhd.Duration; d != 32 * 1024 {
+      t.Fatal(d)
+   }
+   mvhd := file.Moov.Mvhd
+   if mvhd.Duration != 32 * 1024 * uint64(mvhd.Timescale) / 48000 {
+      t.Fatal(mvhd.Duration)
+   }
+   stbl := trak.Mdia.Minf.Stbl
+   if stbl.Stss != nil || stbl.Ctts != nil || len(stbl.Stco.ChunkOffset) != 4 {
+      t.Fatal(stbl)
+   }
+   // first sample of the second fragment
+   ranges, err := trak.GetRangesForSampleInterval(9, 9)
+   if err != nil {
+      t.Fatal(err)
+   }
+   start := ranges[0].Offset
+   sample := buf.Bytes()[start:start + ranges[0].Size]
+   if !bytes.Equal(sample, bytes.Repeat([]byte{2, 0}, 100)) {
+      t.Fatal(sample)
+   }
+}
+
+func Test_Tables(t *testing.T) {
+   init := mp4.CreateEmptyInit()
+   init.AddEmptyTrack(90000, "video", "und")
+   chunks := []chunk{
+      {track: 0, samples: []mp4.FullSample{
+         {Sample: mp4.Sample{Dur: 3000, Flags: 0x2000000, Size: 9}},
+         {Sample: mp4.Sample{
+            Dur: 3000, Flags: 0x1010000, Size: 8, CompositionTimeOffset: 3000,
+         }},
+      }},
+      {track: 0, samples: []mp4.FullSample{
+         {Sample: mp4.Sample{Dur: 3000, Flags: 0x2000000, Size: 7}},
+      }},
+   }
+   trak := init.Moov.Trak
+   set_tables(trak, chunks, 0)
+   stbl := trak.Mdia.Minf.Stbl
+   if len(stbl.Stss.SampleNumber) != 2 || stbl.Stss.SampleNumber[1] != 3 {
+      t.Fatal(stbl.Stss)
+   }
+   if len(stbl.Ctts.SampleCount) != 3 {
+      t.Fatal(stbl.Ctts)
+   }
+   if len(stbl.Stsc.FirstChunk) != 2 || stbl.Stsc.FirstChunk[1] != 2 {
+      t.Fatal(stbl.Stsc)
+   }
+   if stbl.Stts.SampleCount[0] != 3 || stbl.Stsz.SampleNumber != 3 {
+      t.Fatal(stbl.Stts, stbl.Stsz)
+   }
+   trak.Mdia.Mdhd.Timescale = 0
+   if err := set_durations(init.Moov); err == nil {
+      t.Fatal("zero timescale")
+   }
+}
@@ -0,0 +1,128 @@
+package mp4
+
+import (
+   "encoding/hex"
+   "errors"
+   "github.com/edgeware/mp4ff/mp4"
+   "io"
+   "strings"
+)
+
+type PSSH struct {
+   Data []byte
+   KIDs []string
+   System string
+   System_ID string
+}
+
+type Track struct {
+   Codec string
+   Constant_IV string
+   Crypt_Byte_Block uint8
+   Default_KID string
+   Handler string
+   ID uint32
+   IV_Size uint8
+   Scheme string
+   Skip_Byte_Block uint8
+}
+
+type Protection struct {
+   PSSH []PSSH
+   Tracks []Track
+}
+
+// read only, so unlike Decrypt.Init nothing is removed. Reading stops after
+// the moov, so this works with a whole file as well as an init segment.
+func Inspect(r io.Reader) (*Protection, error) {
+   moov, err := read_moov(r)
+   if err != nil {
+      return nil, err
+   }
+   return new_protection(moov), nil
+}
+
+func read_moov(r io.Reader) (*mp4.MoovBox, error) {
+   var pos uint64
+   for {
+      box, err := mp4.DecodeBox(pos, r)
+      if err == io.EOF {
+         return nil, errors.New("moov not found")
+      }
+      if err != nil {
+         return nil, err
+      }
+      moov, ok := box.(*mp4.MoovBox)
+      if ok {
+         return moov, nil
+      }
+      pos += box.Size()
+   }
+}
+
+func new_protection(moov *mp4.MoovBox) *Protection {
+   var pro Protection
+   for _, box := range moov.Psshs {
+      pssh := PSSH{
+         Data: box.Data,
+         System: system_name(box.SystemID),
+         System_ID: box.SystemID.String(),
+      }
+      for _, kid := range box.KIDs {
+         pssh.KIDs = append(pssh.KIDs, kid.String())
+      }
+      pro.PSSH = append(pro.PSSH, pssh)
+   }
+   for _, trak := range moov.Traks {
+      track := Track{ID: trak.Tkhd.TrackID}
+      if trak.Mdia.Hdlr != nil {
+         track.Handler = trak.Mdia.Hdlr.HandlerType
+      }
+      for _, child := range trak.Mdia.Minf.Stbl.Stsd.Children {
+         track.Codec = child.Type()
+         var sinf *mp4.SinfBox
+         switch box := child.(type) {
+         case *mp4.AudioSampleEntryBox:
+            sinf = box.Sinf
+         case *mp4.VisualSampleEntryBox:
+            sinf = box.Sinf
+         }
+         if sinf != nil {
+            track.protect(sinf)
+         }
+      }
+      pro.Tracks = append(pro.Tracks, track)
+   }
+   return &pro
+}
+
+func (t *Track) protect(sinf *mp4.SinfBox) {
+   if sinf.Frma != nil {
+      t.Codec = sinf.Frma.DataFormat
+   }
+   if sinf.Schm != nil {
+      t.Scheme = sinf.Schm.SchemeType
+   }
+   if sinf.Schi != nil && sinf.Schi.Tenc != nil {
+      tenc := sinf.Schi.Tenc
+      t.Constant_IV = hex.EncodeToString(tenc.DefaultConstantIV)
+      t.Crypt_Byte_Block = tenc.DefaultCryptByteBlock
+      t.Default_KID = tenc.DefaultKID.String()
+      t.IV_Size = tenc.DefaultPerSampleIVSize
+      t.Skip_Byte_Block = tenc.DefaultSkipByteBlock
+   }
+}
+
+func system_name(id mp4.UUID) string {
+   switch strings.ToLower(id.String()) {
+   case mp4.UUIDPlayReady:
+      return "PlayReady"
+   case mp4.UUIDWidevine:
+      return "Widevine"
+   case strings.ToLower(mp4.UUIDFairPlay):
+      return "FairPlay"
+   case mp4.UUID_VCAS:
+      return "Verimatrix VCAS"
+   }
+   return ""
+}
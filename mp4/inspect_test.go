@@ -0,0 +1,64 @@
+package mp4
+
+import (
+   "bytes"
+   "encoding/json"
+   "github.com/edgeware/mp4ff/mp4"
+   "os"
+   "testing"
+)
+
+func Test_Inspect(t *testing.T) {
+   init := mp4.CreateEmptyInit()
+   init.AddEmptyTrack(48000, "audio", "en")
+   enca := mp4.CreateAudioSampleEntryBox(
+      "enca", 2, 16, 48000, mp4.CreateEsdsBox([]byte{0x11, 0x90}),
+   )
+   sinf := new(mp4.SinfBox)
+   sinf.AddChild(&mp4.FrmaBox{DataFormat: "mp4a"})
+   sinf.AddChild(&mp4.SchmBox{SchemeType: "cbcs", SchemeVersion: 0x10000})
+   schi := new(mp4.SchiBox)
+   schi.AddChild(&mp4.TencBox{
+      Version: 1,
+      DefaultCryptByteBlock: 1,
+      DefaultSkipByteBlock: 9,
+      DefaultIsProtected: 1,
+      DefaultKID: bytes.Repeat([]byte{0xaa}, 16),
+      DefaultConstantIV: bytes.Repeat([]byte{0xbb}, 16),
+   })
+   sinf.AddChild(schi)
+   enca.AddChild(sinf)
+   init.Moov.Trak.Mdia.Minf.Stbl.Stsd.AddChild(enca)
+   init.Moov.AddChild(&mp4.PsshBox{
+      Version: 1,
+      SystemID: []byte{
+         0xed, 0xef, 0x8b, 0xa9, 0x79, 0xd6, 0x4a, 0xce,
+         0xa3, 0xc8, 0x27, 0xdc, 0xd5, 0x1d, 0x21, 0xed,
+      },
+      KIDs: []mp4.UUID{bytes.Repeat([]byte{0xaa}, 16)},
+   })
+   var buf bytes.Buffer
+   if err := init.Encode(&buf); err != nil {
+      t.Fatal(err)
+   }
+   pro, err := Inspect(&buf)
+   if err != nil {
+      t.Fatal(err)
+   }
+   track := pro.Tracks[0]
+   if track.Codec != "mp4a" || track.Handler != "soun" {
+      t.Fatal(track)
+   }
+   if track.Scheme != "cbcs" || track.Skip_Byte_Block != 9 {
+      t.Fatal(track)
+   }
+   if track.Default_KID != "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa" {
+      t.Fatal(track)
+   }
+   if pro.PSSH[0].System != "Widevine" || len(pro.PSSH[0].KIDs) != 1 {
+      t.Fatal(pro.PSSH)
+   }
+   enc := json.NewEncoder(os.Stdout)
+   enc.SetIndent("", " ")
+   enc.Encode(pro)
+}
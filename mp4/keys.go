@@ -0,0 +1,128 @@
+package mp4
+
+import (
+   "encoding/hex"
+   "github.com/edgeware/mp4ff/mp4"
+   "strings"
+)
+
+type key_func func(kid []byte) ([]byte, error)
+
+// KID to key. KID is lowercase hex without dashes, use Add to normalize it.
+type Keys map[string][]byte
+
+// KID can be in the default_KID form, with dashes and uppercase
+func (k Keys) Add(kid string, key []byte) error {
+   kid = strings.ToLower(strings.ReplaceAll(kid, "-", ""))
+   buf, err := hex.DecodeString(kid)
+   if err != nil {
+      return err
+   }
+   k[hex.EncodeToString(buf)] = key
+   return nil
+}
+
+func (k Keys) get(kid []byte) ([]byte, error) {
+   key, ok := k[hex.EncodeToString(kid)]
+   if !ok {
+      return nil, Key_Error{kid}
+   }
+   return key, nil
+}
+
+// no key was given for this KID
+type Key_Error struct {
+   KID []byte
+}
+
+func (k Key_Error) Error() string {
+   return "key not found for KID " + hex.EncodeToString(k.KID)
+}
+
+// per sample encryption parameters, from tenc or from a seig sample group
+type protection struct {
+   constant_iv []byte
+   crypt byte
+   kid []byte
+   protected bool
+   skip byte
+}
+
+func (p protection) tenc() *mp4.TencBox {
+   var tenc mp4.TencBox
+   tenc.DefaultCryptByteBlock = p.crypt
+   tenc.DefaultSkipByteBlock = p.skip
+   return &tenc
+}
+
+func seig_entries(sgpd *mp4.SgpdBox) []*mp4.SeigSampleGroupEntry {
+   if sgpd.GroupingType != "seig" {
+      return nil
+   }
+   var entries []*mp4.SeigSampleGroupEntry
+   for _, entry := range sgpd.SampleGroupEntries {
+      seig, ok := entry.(*mp4.SeigSampleGroupEntry)
+      if ok {
+         entries = append(entries, seig)
+      }
+   }
+   return entries
+}
+
+// group_description_index from sbgp, where 0 means no group, and above
+// 0x10000 means the sgpd inside the fragment
+func sample_group(traf *mp4.TrafBox, sample int) uint32 {
+   for _, child := range traf.Children {
+      sbgp, ok := child.(*mp4.SbgpBox)
+      if !ok || sbgp.GroupingType != "seig" {
+         continue
+      }
+      for i, count := range sbgp.SampleCounts {
+         if sample < int(count) {
+            return sbgp.GroupDescriptionIndices[i]
+         }
+         sample -= int(count)
+      }
+   }
+   return 0
+}
+
+func (d Decrypt) protection(
+   traf *mp4.TrafBox, sinf *mp4.SinfBox, sample int,
+) protection {
+   tenc := sinf.Schi.Tenc
+   prot := protection{
+      constant_iv: tenc.DefaultConstantIV,
+      crypt: tenc.DefaultCryptByteBlock,
+      kid: tenc.DefaultKID,
+      protected: tenc.DefaultIsProtected == 1,
+      skip: tenc.DefaultSkipByteBlock,
+   }
+   var entries []*mp4.SeigSampleGroupEntry
+   group := sample_group(traf, sample)
+   switch {
+   case group == 0:
+      return prot
+   case group > 0x10000:
+      group -= 0x10000
+      for _, child := range traf.Children {
+         sgpd, ok := child.(*mp4.SgpdBox)
+         if ok {
+            entries = append(entries, seig_entries(sgpd)...)
+         }
+      }
+   default:
+      entries = d.seig[traf.Tfhd.TrackID]
+   }
+   if int(group) > len(entries) {
+      return prot
+   }
+   seig := entries[group-1]
+   return protection{
+      constant_iv: seig.ConstantIV,
+      crypt: seig.CryptByteBlock,
+      kid: seig.KID,
+      protected: seig.IsProtected == 1,
+      skip: seig.SkipByteBlock,
+   }
+}
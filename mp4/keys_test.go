@@ -0,0 +1,69 @@
+package mp4
+
+import (
+   "bytes"
+   "errors"
+   "github.com/edgeware/mp4ff/mp4"
+   "testing"
+)
+
+func Test_Keys(t *testing.T) {
+   keys := make(Keys)
+   err := keys.Add("28339AD7-8F73-4520-DA24-E6E0573D392E", []byte{1})
+   if err != nil {
+      t.Fatal(err)
+   }
+   kid := []byte{
+      0x28, 0x33, 0x9a, 0xd7, 0x8f, 0x73, 0x45, 0x20,
+      0xda, 0x24, 0xe6, 0xe0, 0x57, 0x3d, 0x39, 0x2e,
+   }
+   key, err := keys.get(kid)
+   if err != nil {
+      t.Fatal(err)
+   }
+   if !bytes.Equal(key, []byte{1}) {
+      t.Fatal(key)
+   }
+   kid[0] = 0
+   _, err = keys.get(kid)
+   var key_err Key_Error
+   if !errors.As(err, &key_err) {
+      t.Fatal(err)
+   }
+   if !bytes.Equal(key_err.KID, kid) {
+      t.Fatal(key_err)
+   }
+}
+
+func Test_Protection(t *testing.T) {
+   var sinf mp4.SinfBox
+   sinf.Schi = &mp4.SchiBox{Tenc: &mp4.TencBox{
+      DefaultIsProtected: 1, DefaultKID: make([]byte, 16),
+   }}
+   traf := new(mp4.TrafBox)
+   traf.AddChild(&mp4.TfhdBox{TrackID: 1})
+   traf.AddChild(&mp4.SbgpBox{
+      GroupingType: "seig",
+      SampleCounts: []uint32{1, 1, 1},
+      GroupDescriptionIndices: []uint32{0, 1, 0x10001},
+   })
+   track_kid := bytes.Repeat([]byte{1}, 16)
+   frag_kid := bytes.Repeat([]byte{2}, 16)
+   traf.AddChild(&mp4.SgpdBox{
+      GroupingType: "seig",
+      SampleGroupEntries: []mp4.SampleGroupEntry{
+         &mp4.SeigSampleGroupEntry{IsProtected: 1, KID: frag_kid},
+      },
+   })
+   dec := New_Decrypt(nil)
+   dec.seig[1] = []*mp4.SeigSampleGroupEntry{
+      {IsProtected: 1, KID: track_kid},
+   }
+   kids := [][]byte{make([]byte, 16), track_kid, frag_kid}
+   for i, kid := range kids {
+      prot := dec.protection(traf, &sinf, i)
+      if !bytes.Equal(prot.kid, kid) {
+         t.Fatal(i, prot.kid)
+      }
+   }
+}
@@ -0,0 +1,348 @@
+package mp4
+
+import (
+   "bytes"
+   "encoding/binary"
+   "encoding/hex"
+   "github.com/89z/rosso/dash"
+   "github.com/89z/rosso/hls"
+   "github.com/edgeware/mp4ff/mp4"
+   "io"
+   "math"
+   "strconv"
+   "strings"
+)
+
+// what the init says about a track, to compare with the manifest
+type Media struct {
+   Channels uint16
+   Codec string
+   // zero if unknown
+   Frame_Rate float64
+   Handler string
+   Height uint16
+   Language string
+   Sample_Rate uint16
+   Track_ID uint32
+   Width uint16
+}
+
+// reading stops after the moov, so this works with a whole file as well as
+// an init segment
+func Media_Info(r io.Reader) ([]Media, error) {
+   moov, err := read_moov(r)
+   if err != nil {
+      return nil, err
+   }
+   var media []Media
+   for _, trak := range moov.Traks {
+      med := Media{
+         Language: trak.Mdia.Mdhd.GetLanguage(),
+         Track_ID: trak.Tkhd.TrackID,
+      }
+      if trak.Mdia.Hdlr != nil {
+         med.Handler = trak.Mdia.Hdlr.HandlerType
+      }
+      if trak.Mdia.Elng != nil {
+         med.Language = trak.Mdia.Elng.Language
+      }
+      for _, child := range trak.Mdia.Minf.Stbl.Stsd.Children {
+         switch box := child.(type) {
+         case *mp4.AudioSampleEntryBox:
+            med.Channels = box.ChannelCount
+            med.Codec = audio_codec(box)
+            med.Sample_Rate = box.SampleRate
+         case *mp4.VisualSampleEntryBox:
+            med.Codec = visual_codec(box)
+            med.Height = box.Height
+            med.Width = box.Width
+         case *mp4.UnknownBox:
+            // mp4ff has no av01 or vp09 sample entry
+            med.Codec, med.Width, med.Height = raw_codec(box)
+         default:
+            med.Codec = box.Type()
+         }
+      }
+      if med.Width >= 1 {
+         med.Frame_Rate = frame_rate(moov, trak)
+      }
+      media = append(media, med)
+   }
+   return media, nil
+}
+
+// RFC 6381, for example mp4a.40.2
+func audio_codec(entry *mp4.AudioSampleEntryBox) string {
+   name := entry.Type()
+   if entry.Sinf != nil && entry.Sinf.Frma != nil {
+      name = entry.Sinf.Frma.DataFormat
+   }
+   if name != "mp4a" || entry.Esds == nil {
+      return name
+   }
+   dec := entry.Esds.DecConfigDescriptor
+   if dec.ObjectType != 0x40 {
+      return name + "." + hex.EncodeToString([]byte{dec.ObjectType})
+   }
+   name += ".40"
+   config := dec.DecSpecificInfo.DecConfig
+   if len(config) >= 1 {
+      kind := config[0] >> 3
+      // escape value, with the real type in the next 6 bits
+      if kind == 31 && len(config) >= 2 {
+         kind = 32 + (config[0] & 7) << 3 + config[1] >> 5
+      }
+      name += "." + strconv.Itoa(int(kind))
+   }
+   return name
+}
+
+// RFC 6381, for example avc1.64001f or hvc1.2.4.L123.B0
+func visual_codec(entry *mp4.VisualSampleEntryBox) string {
+   name := entry.Type()
+   if entry.Sinf != nil && entry.Sinf.Frma != nil {
+      name = entry.Sinf.Frma.DataFormat
+   }
+   switch {
+   case entry.AvcC != nil:
+      rec := entry.AvcC.DecConfRec
+      return name + "." + hex.EncodeToString([]byte{
+         rec.AVCProfileIndication,
+         rec.ProfileCompatibility,
+         rec.AVCLevelIndication,
+      })
+   case entry.HvcC != nil:
+      rec := entry.HvcC.DecConfRec
+      var b strings.Builder
+      b.WriteString(name)
+      b.WriteByte('.')
+      if rec.GeneralProfileSpace >= 1 {
+         b.WriteByte('A' + rec.GeneralProfileSpace - 1)
+      }
+      b.WriteString(strconv.Itoa(int(rec.GeneralProfileIDC)))
+      b.WriteByte('.')
+      // bits in reverse order
+      var compat uint32
+      for i := 0; i < 32; i++ {
+         compat = compat << 1 | rec.GeneralProfileCompatibilityFlags >> i & 1
+      }
+      b.WriteString(strconv.FormatUint(uint64(compat), 16))
+      if rec.GeneralTierFlag {
+         b.WriteString(".H")
+      } else {
+         b.WriteString(".L")
+      }
+      b.WriteString(strconv.Itoa(int(rec.GeneralLevelIDC)))
+      // six bytes, without the trailing zero bytes
+      flags := rec.GeneralConstraintIndicatorFlags << 16
+      for flags != 0 {
+         b.WriteByte('.')
+         b.WriteString(strings.ToUpper(strconv.FormatUint(flags >> 56, 16)))
+         flags <<= 8
+      }
+      return b.String()
+   }
+   return name
+}
+
+// sample entry header is 78 bytes, then the child boxes
+func raw_codec(box *mp4.UnknownBox) (string, uint16, uint16) {
+   var buf bytes.Buffer
+   box.Encode(&buf)
+   data := buf.Bytes()
+   if len(data) < 8 + 78 {
+      return box.Type(), 0, 0
+   }
+   width := binary.BigEndian.Uint16(data[8+24:])
+   height := binary.BigEndian.Uint16(data[8+26:])
+   for data = data[8+78:]; len(data) >= 8; {
+      size := binary.BigEndian.Uint32(data)
+      if size < 8 || int(size) > len(data) {
+         break
+      }
+      payload := data[8:size]
+      switch string(data[4:8]) {
+      case "av1C":
+         if len(payload) >= 3 {
+            return av1_codec(payload), width, height
+         }
+      case "vpcC":
+         if len(payload) >= 7 {
+            return vp9_codec(payload), width, height
+         }
+      }
+      data = data[size:]
+   }
+   return box.Type(), width, height
+}
+
+// av01.P.LLT.DD
+func av1_codec(c []byte) string {
+   profile := c[1] >> 5
+   level := c[1] & 0x1F
+   tier := "M"
+   if c[2] >> 7 == 1 {
+      tier = "H"
+   }
+   depth := 8
+   if c[2] >> 6 & 1 == 1 {
+      depth = 10
+      if c[2] >> 5 & 1 == 1 {
+         depth = 12
+      }
+   }
+   var b []byte
+   b = append(b, "av01."...)
+   b = strconv.AppendInt(b, int64(profile), 10)
+   b = append(b, '.')
+   b = append(b, two_digits(int(level))...)
+   b = append(b, tier...)
+   b = append(b, '.')
+   b = append(b, two_digits(depth)...)
+   return string(b)
+}
+
+// vp09.PP.LL.DD, after the version and flags
+func vp9_codec(c []byte) string {
+   var b []byte
+   b = append(b, "vp09."...)
+   b = append(b, two_digits(int(c[4]))...)
+   b = append(b, '.')
+   b = append(b, two_digits(int(c[5]))...)
+   b = append(b, '.')
+   b = append(b, two_digits(int(c[6] >> 4))...)
+   return string(b)
+}
+
+func two_digits(i int) string {
+   if i <= 9 {
+      return "0" + strconv.Itoa(i)
+   }
+   return strconv.Itoa(i)
+}
+
+// from stts, or trex for a fragmented file
+func frame_rate(moov *mp4.MoovBox, trak *mp4.TrakBox) float64 {
+   var duration uint32
+   stts := trak.Mdia.Minf.Stbl.Stts
+   if stts != nil && len(stts.SampleTimeDelta) >= 1 {
+      duration = stts.SampleTimeDelta[0]
+   } else if moov.Mvex != nil {
+      trex, ok := moov.Mvex.GetTrex(trak.Tkhd.TrackID)
+      if ok {
+         duration = trex.DefaultSampleDuration
+      }
+   }
+   if duration == 0 {
+      return 0
+   }
+   return float64(trak.Mdia.Mdhd.Timescale) / float64(duration)
+}
+
+// manifest value that does not match the init
+type Mismatch struct {
+   Field string
+   Manifest string
+   Media string
+}
+
+func (m Mismatch) String() string {
+   return m.Field + ": manifest " + m.Manifest + ", media " + m.Media
+}
+
+// CODECS lists every rendition, so the codec only has to be one of them
+func (m Media) Check_Stream(s hls.Stream) []Mismatch {
+   var mis []Mismatch
+   if s.Codecs != "" {
+      var found bool
+      for _, codec := range strings.Split(s.Codecs, ",") {
+         if strings.EqualFold(strings.TrimSpace(codec), m.Codec) {
+            found = true
+         }
+      }
+      if !found {
+         mis = append(mis, Mismatch{"Codecs", s.Codecs, m.Codec})
+      }
+   }
+   if s.Resolution != "" && m.Width >= 1 {
+      res := m.resolution()
+      if s.Resolution != res {
+         mis = append(mis, Mismatch{"Resolution", s.Resolution, res})
+      }
+   }
+   return mis
+}
+
+func (m Media) Check_Representation(r dash.Representation) []Mismatch {
+   var mis []Mismatch
+   if r.Codecs != "" && !strings.EqualFold(r.Codecs, m.Codec) {
+      mis = append(mis, Mismatch{"Codecs", r.Codecs, m.Codec})
+   }
+   if m.Width >= 1 {
+      if r.Width >= 1 && r.Width != int64(m.Width) ||
+         r.Height >= 1 && r.Height != int64(m.Height) {
+         res := strconv.FormatInt(r.Width, 10) + "x" +
+            strconv.FormatInt(r.Height, 10)
+         mis = append(mis, Mismatch{"Resolution", res, m.resolution()})
+      }
+      // zero is unknown, as with a fragmented init without trex duration
+      rate, ok := parse_rate(r.FrameRate)
+      if ok && m.Frame_Rate > 0 && math.Abs(rate - m.Frame_Rate) >= 0.01 {
+         mis = append(mis, Mismatch{
+            "FrameRate", r.FrameRate,
+            strconv.FormatFloat(m.Frame_Rate, 'f', -1, 64),
+         })
+      }
+   }
+   if m.Sample_Rate >= 1 && r.AudioSamplingRate != "" {
+      rate := strconv.Itoa(int(m.Sample_Rate))
+      if r.AudioSamplingRate != rate {
+         mis = append(mis, Mismatch{
+            "AudioSamplingRate", r.AudioSamplingRate, rate,
+         })
+      }
+   }
+   con := r.AudioChannelConfiguration
+   if m.Channels >= 1 && con != nil && strings.HasSuffix(
+      con.SchemeIdUri, ":23003:3:audio_channel_configuration:2011",
+   ) {
+      channels := strconv.Itoa(int(m.Channels))
+      if con.Value != channels {
+         mis = append(mis, Mismatch{
+            "AudioChannelConfiguration", con.Value, channels,
+         })
+      }
+   }
+   // mdhd is ISO 639-2 and elng is BCP 47, so only compare the same length
+   if r.Adaptation != nil && len(r.Adaptation.Lang) == len(m.Language) {
+      if m.Language != "und" && r.Adaptation.Lang != m.Language {
+         mis = append(mis, Mismatch{"Lang", r.Adaptation.Lang, m.Language})
+      }
+   }
+   return mis
+}
+
+func (m Media) resolution() string {
+   var b []byte
+   b = strconv.AppendInt(b, int64(m.Width), 10)
+   b = append(b, 'x')
+   b = strconv.AppendInt(b, int64(m.Height), 10)
+   return string(b)
+}
+
+// 25 or 30000/1001
+func parse_rate(s string) (float64, bool) {
+   num, den, ok := strings.Cut(s, "/")
+   n, err := strconv.ParseFloat(num, 64)
+   if err != nil {
+      return 0, false
+   }
+   if !ok {
+      return n, true
+   }
+   d, err := strconv.ParseFloat(den, 64)
+   if err != nil || d == 0 {
+      return 0, false
+   }
+   return n / d, true
+}
@@ -0,0 +1,99 @@
+package mp4
+
+import (
+   "github.com/89z/rosso/dash"
+   "github.com/89z/rosso/hls"
+   "github.com/edgeware/mp4ff/hevc"
+   "github.com/edgeware/mp4ff/mp4"
+   "testing"
+)
+
+func Test_Media(t *testing.T) {
+   init, _, err := clear_fixture.encode()
+   if err != nil {
+      t.Fatal(err)
+   }
+   media, err := Media_Info(init)
+   if err != nil {
+      t.Fatal(err)
+   }
+   med := media[0]
+   if med.Codec != "mp4a.40.2" || med.Channels != 2 {
+      t.Fatal(med)
+   }
+   if med.Sample_Rate != 48000 || med.Language != "en" {
+      t.Fatal(med)
+   }
+   mis := med.Check_Stream(hls.Stream{Codecs: "avc1.64001f,mp4a.40.2"})
+   if mis != nil {
+      t.Fatal(mis)
+   }
+   mis = med.Check_Representation(dash.Representation{
+      AudioChannelConfiguration: &dash.Descriptor{
+         SchemeIdUri:
+         "urn:mpeg:dash:23003:3:audio_channel_configuration:2011",
+         Value: "6",
+      },
+      AudioSamplingRate: "44100",
+      Adaptation: &dash.Adaptation{Lang: "fr"},
+      Codecs: "mp4a.40.5",
+   })
+   if len(mis) != 4 {
+      t.Fatal(mis)
+   }
+   if mis[0].String() != "Codecs: manifest mp4a.40.5, media mp4a.40.2" {
+      t.Fatal(mis[0])
+   }
+}
+
+func Test_Visual_Codec(t *testing.T) {
+   var rec hevc.DecConfRec
+   rec.GeneralProfileIDC = 2
+   rec.GeneralProfileCompatibilityFlags = 0x20000000
+   rec.GeneralLevelIDC = 123
+   rec.GeneralConstraintIndicatorFlags = 0xB00000000000
+   entry := mp4.CreateVisualSampleEntryBox(
+      "hvc1", 1920, 1080, &mp4.HvcCBox{DecConfRec: rec},
+   )
+   if codec := visual_codec(entry); codec != "hvc1.2.4.L123.B0" {
+      t.Fatal(codec)
+   }
+   if codec := av1_codec([]byte{0x81, 0x08, 0x0C}); codec != "av01.0.08M.08" {
+      t.Fatal(codec)
+   }
+   med := Media{
+      Codec: "hvc1.2.4.L123.B0", Frame_Rate: 25, Height: 1080, Width: 1920,
+   }
+   mis := med.Check_Representation(dash.Representation{
+      FrameRate: "30000/1001", Height: 1080, Width: 1920,
+   })
+   if len(mis) != 1 || mis[0].Field != "FrameRate" {
+      t.Fatal(mis)
+   }
+   mis = med.Check_Stream(hls.Stream{Resolution: "1280x720"})
+   if len(mis) != 1 || mis[0].Media != "1920x1080" {
+      t.Fatal(mis)
+   }
+}
+
+// empty stts, and trex without default_sample_duration
+func Test_Media_Frame_Rate(t *testing.T) {
+   init, _, err := video_fixture.encode()
+   if err != nil {
+      t.Fatal(err)
+   }
+   media, err := Media_Info(init)
+   if err != nil {
+      t.Fatal(err)
+   }
+   med := media[0]
+   if med.Frame_Rate != 0 {
+      t.Fatal(med)
+   }
+   mis := med.Check_Representation(dash.Representation{
+      FrameRate: "24", Height: 360, Width: 640,
+   })
+   if mis != nil {
+      t.Fatal(mis)
+   }
+}
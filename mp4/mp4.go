@@ -1,104 +1,252 @@
 package mp4
 
 import (
+   "errors"
    "github.com/edgeware/mp4ff/mp4"
    "io"
+   "sync"
 )
 
 type Decrypt struct {
+   // samples are decrypted by this many goroutines, with zero or one meaning
+   // no goroutines. Output is the same either way.
+   Workers int
+   // check the structure of the first few decrypted samples of each traf,
+   // and return Verify_Error if they look like garbage
+   Verify bool
+   entry map[uint32]verify_entry
+   seig map[uint32][]*mp4.SeigSampleGroupEntry
    sinf map[uint32]*mp4.SinfBox
+   trex map[uint32]*mp4.TrexBox
    write io.Writer
 }
 
 func New_Decrypt(w io.Writer) Decrypt {
    var dec Decrypt
+   dec.entry = make(map[uint32]verify_entry)
+   dec.seig = make(map[uint32][]*mp4.SeigSampleGroupEntry)
    dec.sinf = make(map[uint32]*mp4.SinfBox)
+   dec.trex = make(map[uint32]*mp4.TrexBox)
    dec.write = w
    return dec
 }
 
+// r is read to the end, so it should be the init only. Stream can take the
+// init as well, without Init.
 func (d *Decrypt) Init(r io.Reader) error {
    file, err := mp4.DecodeFile(r)
    if err != nil {
       return err
    }
+   if file.Init == nil {
+      return errors.New("init not found")
+   }
+   return d.init(file.Init)
+}
+
+func (d *Decrypt) init(init *mp4.InitSegment) error {
+   if init.Moov.Mvex != nil {
+      for _, trex := range init.Moov.Mvex.Trexs {
+         d.trex[trex.TrackID] = trex
+      }
+   }
+   var err error
    // need for VLC media player
-   for _, trak := range file.Init.Moov.Traks {
+   for _, trak := range init.Moov.Traks {
+      for _, sgpd := range trak.Mdia.Minf.Stbl.Sgpds {
+         d.seig[trak.Tkhd.TrackID] = append(
+            d.seig[trak.Tkhd.TrackID], seig_entries(sgpd)...,
+         )
+      }
       for _, child := range trak.Mdia.Minf.Stbl.Stsd.Children {
          switch box := child.(type) {
          case *mp4.AudioSampleEntryBox:
-            d.sinf[trak.Tkhd.TrackID], err = box.RemoveEncryption()
+            if box.Type() == "enca" {
+               d.sinf[trak.Tkhd.TrackID], err = box.RemoveEncryption()
+            }
+            d.entry[trak.Tkhd.TrackID] = verify_entry{aac: aac_entry(box)}
          case *mp4.VisualSampleEntryBox:
-            d.sinf[trak.Tkhd.TrackID], err = box.RemoveEncryption()
+            if box.Type() == "encv" {
+               d.sinf[trak.Tkhd.TrackID], err = box.RemoveEncryption()
+            }
+            d.entry[trak.Tkhd.TrackID] = verify_entry{
+               size: nal_length_size(box),
+            }
          }
          if err != nil {
             return err
          }
       }
+      if sinf := d.sinf[trak.Tkhd.TrackID]; sinf != nil {
+         err := check_scheme(sinf)
+         if err != nil {
+            return err
+         }
+      }
    }
    // need for Mozilla Firefox
-   file.Init.Moov.RemovePsshs()
-   return file.Init.Encode(d.write)
+   init.Moov.RemovePsshs()
+   return init.Encode(d.write)
 }
 
+// every encrypted track uses the same key. Only styp, moof and mdat are
+// written, so boxes such as sidx, emsg and prft are dropped.
 func (d Decrypt) Segment(r io.Reader, key []byte) error {
+   return d.segment(r, func([]byte) ([]byte, error) {
+      return key, nil
+   })
+}
+
+// each track uses the key for its KID
+func (d Decrypt) Segment_Keys(r io.Reader, keys Keys) error {
+   return d.segment(r, keys.get)
+}
+
+func (d Decrypt) segment(r io.Reader, get key_func) error {
    file, err := mp4.DecodeFile(r)
    if err != nil {
       return err
    }
    for _, seg := range file.Segments {
       for _, frag := range seg.Fragments {
-         var removed uint64
-         for _, traf := range frag.Moof.Trafs {
-            sinf := d.sinf[traf.Tfhd.TrackID]
-            if sinf == nil {
-               continue
-            }
-            samples, err := frag.GetFullSamples(nil)
-            if err != nil {
-               return err
+         err := d.fragment(frag, get)
+         if err != nil {
+            return err
+         }
+      }
+      // fix jerk between fragments
+      seg.Sidx = nil
+      err := seg.Encode(d.write)
+      if err != nil {
+         return err
+      }
+   }
+   return nil
+}
+
+func (d Decrypt) fragment(frag *mp4.Fragment, get key_func) error {
+   var removed uint64
+   for _, traf := range frag.Moof.Trafs {
+      sinf := d.sinf[traf.Tfhd.TrackID]
+      if sinf == nil {
+         continue
+      }
+      var scheme string
+      if sinf.Schm != nil {
+         scheme = sinf.Schm.SchemeType
+      }
+      trex := trex_box(d.trex, traf.Tfhd.TrackID)
+      samples, err := frag.GetFullSamples(trex)
+      if err != nil {
+         return err
+      }
+      jobs := make([]sample_job, 0, len(samples))
+      for i, sample := range samples {
+         prot := d.protection(traf, sinf, i)
+         if !prot.protected {
+            continue
+         }
+         key, err := get(prot.kid)
+         if err != nil {
+            return err
+         }
+         iv := prot.constant_iv
+         if iv == nil {
+            if traf.Senc == nil || len(traf.Senc.IVs) <= i {
+               return errors.New("missing senc IV")
             }
-            tenc := sinf.Schi.Tenc
-            for i, sample := range samples {
-               iv := tenc.DefaultConstantIV
-               if iv == nil {
-                  iv = append(iv, traf.Senc.IVs[i]...)
-                  iv = append(iv, 0, 0, 0, 0, 0, 0, 0, 0)
-               }
-               var sub []mp4.SubSamplePattern
-               if len(traf.Senc.SubSamples) > i {
-                  // required for playback
-                  sub = traf.Senc.SubSamples[i]
-               }
-               switch sinf.Schm.SchemeType {
-               case "cenc":
-                  err = mp4.DecryptSampleCenc(sample.Data, key, iv, sub)
-               case "cbcs":
-                  err = mp4.DecryptSampleCbcs(sample.Data, key, iv, sub, tenc)
-               }
-               if err != nil {
-                  return err
-               }
+            iv = append(iv, traf.Senc.IVs[i]...)
+            if len(iv) == 8 {
+               iv = append(iv, 0, 0, 0, 0, 0, 0, 0, 0)
             }
+         }
+         var sub []mp4.SubSamplePattern
+         if traf.Senc != nil && len(traf.Senc.SubSamples) > i {
             // required for playback
-            removed += traf.RemoveEncryptionBoxes()
+            sub = traf.Senc.SubSamples[i]
          }
-         // fast start
-         _, pssh := frag.Moof.RemovePsshs()
-         removed += pssh
-         for _, traf := range frag.Moof.Trafs {
-            for _, trun := range traf.Truns {
-               // required for playback
-               trun.DataOffset -= int32(removed)
-            }
+         jobs = append(jobs, sample_job{sample.Data, i, iv, key, prot, sub})
+      }
+      if err := d.decrypt(scheme, jobs); err != nil {
+         return err
+      }
+      if d.Verify {
+         entry := d.entry[traf.Tfhd.TrackID]
+         entry.format = sinf.Frma.DataFormat
+         err := verify(traf.Tfhd.TrackID, entry, jobs)
+         if err != nil {
+            return err
          }
       }
-      // fix jerk between fragments
-      seg.Sidx = nil
-      err := seg.Encode(d.write)
+      // required for playback
+      removed += traf.RemoveEncryptionBoxes()
+   }
+   // fast start
+   _, pssh := frag.Moof.RemovePsshs()
+   removed += pssh
+   for _, traf := range frag.Moof.Trafs {
+      for _, trun := range traf.Truns {
+         // required for playback
+         trun.DataOffset -= int32(removed)
+      }
+   }
+   return nil
+}
+
+type sample_job struct {
+   data []byte
+   index int
+   iv []byte
+   key []byte
+   prot protection
+   sub []mp4.SubSamplePattern
+}
+
+// samples are independent, so they can be done in any order. The error
+// returned is from the first sample that failed.
+func (d Decrypt) decrypt(scheme string, jobs []sample_job) error {
+   errs := make([]error, len(jobs))
+   do := func(i int) {
+      job := jobs[i]
+      errs[i] = decrypt_sample(
+         scheme, job.data, job.key, job.iv, job.sub, job.prot,
+      )
+   }
+   if d.Workers <= 1 || len(jobs) <= 1 {
+      for i := range jobs {
+         do(i)
+      }
+   } else {
+      var group sync.WaitGroup
+      next := make(chan int)
+      for w := 0; w < d.Workers; w++ {
+         group.Add(1)
+         go func() {
+            defer group.Done()
+            for i := range next {
+               do(i)
+            }
+         }()
+      }
+      for i := range jobs {
+         next <- i
+      }
+      close(next)
+      group.Wait()
+   }
+   for _, err := range errs {
       if err != nil {
          return err
       }
    }
    return nil
 }
+
+func trex_box(trexs map[uint32]*mp4.TrexBox, track uint32) *mp4.TrexBox {
+   trex := trexs[track]
+   if trex == nil {
+      // only TrackID is needed to find the traf
+      return &mp4.TrexBox{TrackID: track}
+   }
+   return trex
+}
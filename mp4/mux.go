@@ -0,0 +1,126 @@
+package mp4
+
+import (
+   "bytes"
+   "errors"
+   "github.com/edgeware/mp4ff/mp4"
+   "io"
+   "sort"
+)
+
+// fragment of one input, with its decode time
+type mux_fragment struct {
+   samples []mp4.FullSample
+   timescale uint32
+   track uint32
+}
+
+func (m mux_fragment) before(n mux_fragment) bool {
+   a := m.samples[0].DecodeTime * uint64(n.timescale)
+   b := n.samples[0].DecodeTime * uint64(m.timescale)
+   return a < b
+}
+
+// each input is a clear single track fragmented file, init followed by
+// segments. Tracks are numbered from 1 in the order of the inputs, and w gets
+// one fragment per input fragment, ordered by decode time.
+func Mux(w io.Writer, inputs ...io.Reader) error {
+   init := mp4.NewMP4Init()
+   moov := mp4.NewMoovBox()
+   mvex := mp4.NewMvexBox()
+   var frags []mux_fragment
+   for i, input := range inputs {
+      file, err := mp4.DecodeFile(input)
+      if err != nil {
+         return err
+      }
+      if file.Init == nil || len(file.Init.Moov.Traks) != 1 {
+         return errors.New("input is not a single track init")
+      }
+      in := file.Init.Moov
+      trak := in.Trak
+      if encrypted(trak) {
+         return errors.New("input is encrypted")
+      }
+      var (
+         trex *mp4.TrexBox
+         ok bool
+      )
+      if in.Mvex != nil {
+         trex, ok = in.Mvex.GetTrex(trak.Tkhd.TrackID)
+      }
+      if !ok {
+         trex = mp4.CreateTrex(trak.Tkhd.TrackID)
+      }
+      for _, seg := range file.Segments {
+         for _, frag := range seg.Fragments {
+            samples, err := frag.GetFullSamples(trex)
+            if err != nil {
+               return err
+            }
+            if len(samples) >= 1 {
+               frags = append(frags, mux_fragment{
+                  samples, trak.Mdia.Mdhd.Timescale, uint32(i+1),
+               })
+            }
+         }
+      }
+      if i == 0 {
+         init.AddChild(file.Init.Ftyp)
+         moov.AddChild(in.Mvhd)
+      }
+      trak.Tkhd.TrackID = uint32(i+1)
+      moov.AddChild(trak)
+      trex.TrackID = uint32(i+1)
+      mvex.AddChild(trex)
+      for _, pssh := range in.Psshs {
+         moov.AddChild(pssh)
+      }
+   }
+   if moov.Mvhd == nil {
+      return errors.New("no inputs")
+   }
+   moov.Mvhd.NextTrackID = uint32(len(inputs)+1)
+   moov.AddChild(mvex)
+   init.AddChild(moov)
+   if err := init.Encode(w); err != nil {
+      return err
+   }
+   sort.SliceStable(frags, func(i, j int) bool {
+      return frags[i].before(frags[j])
+   })
+   for i, m := range frags {
+      frag, err := mp4.CreateFragment(uint32(i+1), m.track)
+      if err != nil {
+         return err
+      }
+      for _, sample := range m.samples {
+         frag.AddFullSample(sample)
+      }
+      if err := frag.Encode(w); err != nil {
+         return err
+      }
+   }
+   return nil
+}
+
+// like Mux, but w gets a progressive file as with Flatten
+func Mux_Flatten(w io.Writer, inputs ...io.Reader) error {
+   var buf bytes.Buffer
+   err := Mux(&buf, inputs...)
+   if err != nil {
+      return err
+   }
+   return Flatten(w, &buf)
+}
+
+// samples are copied into new fragments, so senc would be lost
+func encrypted(trak *mp4.TrakBox) bool {
+   for _, child := range trak.Mdia.Minf.Stbl.Stsd.Children {
+      switch child.Type() {
+      case "enca", "encv":
+         return true
+      }
+   }
+   return false
+}
@@ -0,0 +1,66 @@
+package mp4
+
+import (
+   "bytes"
+   "github.com/edgeware/mp4ff/mp4"
+   "testing"
+)
+
+func Test_Mux(t *testing.T) {
+   audio_init, audio_segs, err := clear_fixture.encode()
+   if err != nil {
+      t.Fatal(err)
+   }
+   audio := append(audio_init.Bytes(), audio_segs.Bytes()...)
+   video_init, video_segs, err := video_fixture.encode()
+   if err != nil {
+      t.Fatal(err)
+   }
+   video := append(video_init.Bytes(), video_segs.Bytes()...)
+   var buf bytes.Buffer
+   err = Mux(&buf, bytes.NewReader(audio), bytes.NewReader(video))
+   if err != nil {
+      t.Fatal(err)
+   }
+   file, err := mp4.DecodeFile(bytes.NewReader(buf.Bytes()))
+   if err != nil {
+      t.Fatal(err)
+   }
+   if len(file.Moov.Traks) != 2 || file.Moov.Traks[1].Tkhd.TrackID != 2 {
+      t.Fatal(file.Moov.Traks)
+   }
+   if len(file.Moov.Mvex.Trexs) != 2 {
+      t.Fatal(file.Moov.Mvex.Trexs)
+   }
+   var tracks []uint32
+   for _, seg := range file.Segments {
+      for _, frag := range seg.Fragments {
+         tracks = append(tracks, frag.Moof.Traf.Tfhd.TrackID)
+      }
+   }
+   want := []uint32{1, 2, 1, 2, 1, 1}
+   if len(tracks) != len(want) {
+      t.Fatal(tracks)
+   }
+   for i := range want {
+      if tracks[i] != want[i] {
+         t.Fatal(tracks)
+      }
+   }
+   buf.Reset()
+   err = Mux_Flatten(
+      &buf, bytes.NewReader(audio), bytes.NewReader(video),
+   )
+   if err != nil {
+      t.Fatal(err)
+   }
+   file, err = mp4.DecodeFile(bytes.NewReader(buf.Bytes()))
+   if err != nil {
+      t.Fatal(err)
+   }
+   for i, count := range []uint32{32, 12} {
+      if n := file.Moov.Traks[i].GetNrSamples(); n != count {
+         t.Fatal(i, n)
+      }
+   }
+}
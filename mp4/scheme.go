@@ -0,0 +1,126 @@
+package mp4
+
+import (
+   "crypto/aes"
+   "crypto/cipher"
+   "errors"
+   "github.com/edgeware/mp4ff/mp4"
+)
+
+// scheme_type from schm is not one of cenc, cbcs, cens or cbc1
+type Scheme_Error struct {
+   Scheme string
+}
+
+func (s Scheme_Error) Error() string {
+   return "unknown protection scheme " + s.Scheme
+}
+
+// fail at the init, rather than pass through data that is still encrypted
+func check_scheme(sinf *mp4.SinfBox) error {
+   if sinf.Schm == nil {
+      return Scheme_Error{}
+   }
+   switch sinf.Schm.SchemeType {
+   case "cenc", "cbcs", "cens", "cbc1":
+      return nil
+   }
+   return Scheme_Error{sinf.Schm.SchemeType}
+}
+
+func decrypt_sample(
+   scheme string, sample, key, iv []byte, sub []mp4.SubSamplePattern,
+   prot protection,
+) error {
+   // mp4ff would panic as well, so every scheme is checked
+   ranges, err := protected_ranges(sample, sub)
+   if err != nil {
+      return err
+   }
+   switch scheme {
+   case "cenc":
+      return mp4.DecryptSampleCenc(sample, key, iv, sub)
+   case "cbcs":
+      return mp4.DecryptSampleCbcs(sample, key, iv, sub, prot.tenc())
+   case "cens":
+      return decrypt_cens(ranges, key, iv, prot)
+   case "cbc1":
+      return decrypt_cbc1(ranges, key, iv)
+   }
+   return Scheme_Error{scheme}
+}
+
+// CTR with pattern. The counter runs across every protected range of the
+// sample, and only advances over the crypt blocks.
+func decrypt_cens(ranges [][]byte, key, iv []byte, prot protection) error {
+   block, err := aes.NewCipher(key)
+   if err != nil {
+      return err
+   }
+   stream := cipher.NewCTR(block, iv)
+   for _, data := range ranges {
+      pattern(data, prot.crypt, prot.skip, func(b []byte) {
+         stream.XORKeyStream(b, b)
+      })
+   }
+   return nil
+}
+
+// full sample CBC. The chain runs across every protected range of the
+// sample, and a partial block at the end of a range is left clear.
+func decrypt_cbc1(ranges [][]byte, key, iv []byte) error {
+   block, err := aes.NewCipher(key)
+   if err != nil {
+      return err
+   }
+   mode := cipher.NewCBCDecrypter(block, iv)
+   for _, data := range ranges {
+      data = data[:len(data) &^ 0xF]
+      mode.CryptBlocks(data, data)
+   }
+   return nil
+}
+
+// without subsamples the whole sample is protected. The sizes come from the
+// senc, so they are checked against the sample.
+func protected_ranges(
+   sample []byte, sub []mp4.SubSamplePattern,
+) ([][]byte, error) {
+   if len(sub) == 0 {
+      return [][]byte{sample}, nil
+   }
+   var (
+      pos uint64
+      ranges [][]byte
+   )
+   for _, pat := range sub {
+      pos += uint64(pat.BytesOfClearData)
+      end := pos + uint64(pat.BytesOfProtectedData)
+      if end > uint64(len(sample)) {
+         return nil, errors.New("subsamples past end of sample")
+      }
+      ranges = append(ranges, sample[pos:end])
+      pos = end
+   }
+   return ranges, nil
+}
+
+// calls f with crypt blocks, then passes over skip blocks, until fewer than
+// crypt blocks remain, which are left clear. Without a pattern f gets all
+// of data.
+func pattern(data []byte, crypt, skip byte, f func([]byte)) {
+   if crypt == 0 && skip == 0 {
+      f(data)
+      return
+   }
+   crypt_size := int(crypt) * 16
+   skip_size := int(skip) * 16
+   for len(data) >= crypt_size {
+      f(data[:crypt_size])
+      data = data[crypt_size:]
+      if len(data) < skip_size {
+         break
+      }
+      data = data[skip_size:]
+   }
+}
@@ -0,0 +1,155 @@
+package mp4
+
+import (
+   "bytes"
+   "crypto/aes"
+   "crypto/cipher"
+   "encoding/hex"
+   "errors"
+   "github.com/edgeware/mp4ff/mp4"
+   "testing"
+)
+
+var scheme_key = bytes.Repeat([]byte{1}, 16)
+
+var scheme_iv = bytes.Repeat([]byte{2}, 16)
+
+func clear_sample() []byte {
+   sample := make([]byte, 200)
+   for i := range sample {
+      sample[i] = byte(i)
+   }
+   return sample
+}
+
+// pairs of clear and protected sizes
+func sub_samples(sizes ...uint32) []mp4.SubSamplePattern {
+   var sub []mp4.SubSamplePattern
+   for i := 0; i < len(sizes); i += 2 {
+      sub = append(sub, mp4.SubSamplePattern{
+         BytesOfClearData: uint16(sizes[i]),
+         BytesOfProtectedData: sizes[i+1],
+      })
+   }
+   return sub
+}
+
+func Test_Cbc1(t *testing.T) {
+   sub := sub_samples(8, 64, 8, 100)
+   sample := clear_sample()
+   block, err := aes.NewCipher(scheme_key)
+   if err != nil {
+      t.Fatal(err)
+   }
+   // the chain continues into the second range
+   enc := cipher.NewCBCEncrypter(block, scheme_iv)
+   enc.CryptBlocks(sample[8:72], sample[8:72])
+   enc.CryptBlocks(sample[80:176], sample[80:176])
+   err = decrypt_sample("cbc1", sample, scheme_key, scheme_iv, sub, protection{})
+   if err != nil {
+      t.Fatal(err)
+   }
+   if !bytes.Equal(sample, clear_sample()) {
+      t.Fatal(sample)
+   }
+}
+
+func Test_Cens(t *testing.T) {
+   sub := sub_samples(8, 64, 8, 100)
+   prot := protection{crypt: 1, skip: 2}
+   sample := clear_sample()
+   err := decrypt_sample("cens", sample, scheme_key, scheme_iv, sub, prot)
+   if err != nil {
+      t.Fatal(err)
+   }
+   clear := clear_sample()
+   // skip blocks, and the partial pattern at the end of each range
+   for _, r := range [][2]int{{0, 8}, {24, 56}, {72, 80}, {96, 128}, {144, 200}} {
+      if !bytes.Equal(sample[r[0]:r[1]], clear[r[0]:r[1]]) {
+         t.Fatal(r)
+      }
+   }
+   // CTR is symmetric, so running it again gives back the clear sample
+   err = decrypt_sample("cens", sample, scheme_key, scheme_iv, sub, prot)
+   if err != nil {
+      t.Fatal(err)
+   }
+   if !bytes.Equal(sample, clear) {
+      t.Fatal(sample)
+   }
+}
+
+// from OpenSSL. The clear sample is bytes 0 to 95, with subsamples of 4
+// clear and 40 protected, then 4 clear and 48 protected.
+var known_answers = []struct {
+   scheme string
+   prot protection
+   sample string
+}{
+   {
+      "cens", protection{crypt: 1, skip: 2},
+      "0001020313d312f471a03f9b7be45b78" +
+      "ed20d0191415161718191a1b1c1d1e1f" +
+      "202122232425262728292a2b2c2d2e2f" +
+      "13bcbf51cca7a8c2ece03c10ccc64962" +
+      "404142434445464748494a4b4c4d4e4f" +
+      "505152535455565758595a5b5c5d5e5f",
+   },
+   {
+      "cbc1", protection{},
+      "000102032cf36ffdc7e9ec4dfd7758cd" +
+      "30555f74e02581c31f5cfdb8ff7e9c5b" +
+      "ed4432c62425262728292a2b2c2d2e2f" +
+      "1732b9c2c4bb4fb68accfeb40bc8db63" +
+      "0789b2df052f063eecc2ec4288882cee" +
+      "783e8865df46b08214d48ce1ff09c7d1",
+   },
+}
+
+func Test_Known_Answer(t *testing.T) {
+   for _, test := range known_answers {
+      sample, err := hex.DecodeString(test.sample)
+      if err != nil {
+         t.Fatal(err)
+      }
+      sub := sub_samples(4, 40, 4, 48)
+      err = decrypt_sample(
+         test.scheme, sample, scheme_key, scheme_iv, sub, test.prot,
+      )
+      if err != nil {
+         t.Fatal(err)
+      }
+      if !bytes.Equal(sample, clear_sample()[:96]) {
+         t.Fatal(test.scheme, hex.EncodeToString(sample))
+      }
+   }
+}
+
+func Test_Protected_Ranges(t *testing.T) {
+   for _, sub := range [][]mp4.SubSamplePattern{
+      sub_samples(8, 64, 8, 200),
+      sub_samples(300, 0),
+      sub_samples(0, 0xFFFFFFFF),
+   } {
+      for _, scheme := range []string{"cenc", "cbcs", "cens", "cbc1"} {
+         err := decrypt_sample(
+            scheme, clear_sample(), scheme_key, scheme_iv, sub, protection{},
+         )
+         if err == nil {
+            t.Fatal(scheme, sub)
+         }
+      }
+   }
+}
+
+func Test_Scheme(t *testing.T) {
+   err := check_scheme(&mp4.SinfBox{Schm: &mp4.SchmBox{SchemeType: "abcd"}})
+   var scheme_err Scheme_Error
+   if !errors.As(err, &scheme_err) || scheme_err.Scheme != "abcd" {
+      t.Fatal(err)
+   }
+   err = decrypt_sample("abcd", nil, scheme_key, scheme_iv, nil, protection{})
+   if !errors.As(err, &scheme_err) {
+      t.Fatal(err)
+   }
+}
@@ -0,0 +1,102 @@
+package mp4
+
+import (
+   "errors"
+   "github.com/edgeware/mp4ff/mp4"
+   "io"
+)
+
+// like Segment, but only one moof and mdat are in memory at a time. The
+// output is the same, so emsg and prft are dropped as well. If r has ftyp and
+// moov, they are done as with Init, so one file such as a SegmentBase can be
+// decrypted without Init. Otherwise call Init first, with the init only.
+func (d Decrypt) Stream(r io.Reader, key []byte) error {
+   return d.stream(r, func([]byte) ([]byte, error) {
+      return key, nil
+   })
+}
+
+// like Segment_Keys, but only one moof and mdat are in memory at a time
+func (d Decrypt) Stream_Keys(r io.Reader, keys Keys) error {
+   return d.stream(r, keys.get)
+}
+
+func (d Decrypt) stream(r io.Reader, get key_func) error {
+   var (
+      ftyp *mp4.FtypBox
+      moof *mp4.MoofBox
+      pos uint64
+   )
+   for {
+      box, err := mp4.DecodeBox(pos, r)
+      if err == io.EOF {
+         break
+      }
+      if err != nil {
+         return err
+      }
+      switch box := box.(type) {
+      case *mp4.FtypBox:
+         ftyp = box
+      case *mp4.MoovBox:
+         init := mp4.NewMP4Init()
+         if ftyp != nil {
+            init.AddChild(ftyp)
+         }
+         init.AddChild(box)
+         if err := d.init(init); err != nil {
+            return err
+         }
+      case *mp4.StypBox:
+         err := box.Encode(d.write)
+         if err != nil {
+            return err
+         }
+      case *mp4.MoofBox:
+         box.StartPos = pos
+         err := d.parse_senc(box)
+         if err != nil {
+            return err
+         }
+         moof = box
+      case *mp4.MdatBox:
+         if moof == nil {
+            return errors.New("mdat without moof")
+         }
+         frag := mp4.NewFragment()
+         frag.AddChild(moof)
+         frag.AddChild(box)
+         if err := d.fragment(frag, get); err != nil {
+            return err
+         }
+         if err := frag.Encode(d.write); err != nil {
+            return err
+         }
+         moof = nil
+      }
+      // everything else, such as sidx, emsg and prft, is dropped like with
+      // Segment
+      pos += box.Size()
+   }
+   return nil
+}
+
+// DecodeBox leaves senc unparsed, as the IV size comes from the tenc
+func (d Decrypt) parse_senc(moof *mp4.MoofBox) error {
+   for _, traf := range moof.Trafs {
+      ok, parsed := traf.ContainsSencBox()
+      if !ok || parsed {
+         continue
+      }
+      var size byte
+      sinf := d.sinf[traf.Tfhd.TrackID]
+      if sinf != nil && sinf.Schi != nil && sinf.Schi.Tenc != nil {
+         size = sinf.Schi.Tenc.DefaultPerSampleIVSize
+      }
+      err := traf.ParseReadSenc(size, moof.StartPos)
+      if err != nil {
+         return err
+      }
+   }
+   return nil
+}
@@ -0,0 +1,93 @@
+package mp4
+
+import (
+   "bytes"
+   "github.com/edgeware/mp4ff/mp4"
+   "testing"
+)
+
+func Test_Stream(t *testing.T) {
+   init, segs, err := clear_fixture.encode()
+   if err != nil {
+      t.Fatal(err)
+   }
+   var file, stream bytes.Buffer
+   dec := New_Decrypt(&file)
+   if err := dec.Init(bytes.NewReader(init.Bytes())); err != nil {
+      t.Fatal(err)
+   }
+   if err := dec.Segment(bytes.NewReader(segs.Bytes()), nil); err != nil {
+      t.Fatal(err)
+   }
+   dec = New_Decrypt(&stream)
+   if err := dec.Init(bytes.NewReader(init.Bytes())); err != nil {
+      t.Fatal(err)
+   }
+   if err := dec.Stream(bytes.NewReader(segs.Bytes()), nil); err != nil {
+      t.Fatal(err)
+   }
+   if !bytes.Equal(file.Bytes(), stream.Bytes()) {
+      t.Fatal(file.Len(), stream.Len())
+   }
+}
+
+func Test_Stream_Encrypted(t *testing.T) {
+   key := bytes.Repeat([]byte{5}, 16)
+   for _, fix := range []fixture{clear_fixture, avc_fixture} {
+      for _, scheme := range []string{"cenc", "cbcs"} {
+         clear, protected, err := fix.encrypt(scheme, key)
+         if err != nil {
+            t.Fatal(err)
+         }
+         var file bytes.Buffer
+         if err := decrypt_file(&file, protected, key); err != nil {
+            t.Fatal(err)
+         }
+         if !bytes.Equal(file.Bytes(), clear) {
+            t.Fatal(fix.handler, scheme, "round trip")
+         }
+         size, err := init_size(protected)
+         if err != nil {
+            t.Fatal(err)
+         }
+         // init only to Init
+         var stream bytes.Buffer
+         dec := New_Decrypt(&stream)
+         if err := dec.Init(bytes.NewReader(protected[:size])); err != nil {
+            t.Fatal(err)
+         }
+         err = dec.Stream(bytes.NewReader(protected[size:]), key)
+         if err != nil {
+            t.Fatal(fix.handler, scheme, err)
+         }
+         if !bytes.Equal(file.Bytes(), stream.Bytes()) {
+            t.Fatal(fix.handler, scheme, file.Len(), stream.Len())
+         }
+         // whole file, without Init
+         stream.Reset()
+         dec = New_Decrypt(&stream)
+         if err := dec.Stream(bytes.NewReader(protected), key); err != nil {
+            t.Fatal(fix.handler, scheme, err)
+         }
+         if !bytes.Equal(file.Bytes(), stream.Bytes()) {
+            t.Fatal(fix.handler, scheme, file.Len(), stream.Len())
+         }
+      }
+   }
+}
+
+// up to the end of moov
+func init_size(file []byte) (int, error) {
+   var pos uint64
+   r := bytes.NewReader(file)
+   for {
+      box, err := mp4.DecodeBox(pos, r)
+      if err != nil {
+         return 0, err
+      }
+      pos += box.Size()
+      if box.Type() == "moov" {
+         return int(pos), nil
+      }
+   }
+}
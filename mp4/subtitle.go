@@ -0,0 +1,456 @@
+package mp4
+
+import (
+   "bytes"
+   "encoding/xml"
+   "errors"
+   "github.com/edgeware/mp4ff/mp4"
+   "html"
+   "io"
+   "strconv"
+   "strings"
+   "time"
+)
+
+type Cue struct {
+   End time.Duration
+   ID string
+   // WebVTT cue settings from sttg, such as line:0 align:start
+   Settings string
+   Start time.Duration
+   // can include WebVTT tags, such as <i> or <c.yellow>
+   Text string
+}
+
+type Subtitles struct {
+   Cues []Cue
+   // vttC, which can have STYLE blocks after WEBVTT
+   Header string
+}
+
+// r is a fragmented file, init followed by segments. The first wvtt or stpp
+// track is used. With stpp, times in the TTML are taken as media time, not
+// time from the start of the sample, and a cue with no end lasts until the
+// end of the sample.
+func Read_Subtitles(r io.Reader) (*Subtitles, error) {
+   file, err := mp4.DecodeFile(r)
+   if err != nil {
+      return nil, err
+   }
+   if file.Init == nil {
+      return nil, errors.New("init not found")
+   }
+   var (
+      format string
+      subs Subtitles
+      trak *mp4.TrakBox
+   )
+   for _, each := range file.Init.Moov.Traks {
+      for _, child := range each.Mdia.Minf.Stbl.Stsd.Children {
+         switch box := child.(type) {
+         case *mp4.WvttBox:
+            if box.VttC != nil {
+               subs.Header = box.VttC.Config
+            }
+            format, trak = "wvtt", each
+         case *mp4.StppBox:
+            format, trak = "stpp", each
+         }
+      }
+      if trak != nil {
+         break
+      }
+   }
+   if trak == nil {
+      return nil, errors.New("wvtt or stpp not found")
+   }
+   var trex *mp4.TrexBox
+   if file.Init.Moov.Mvex != nil {
+      trex, _ = file.Init.Moov.Mvex.GetTrex(trak.Tkhd.TrackID)
+   }
+   if trex == nil {
+      trex = mp4.CreateTrex(trak.Tkhd.TrackID)
+   }
+   scale := trak.Mdia.Mdhd.Timescale
+   for _, seg := range file.Segments {
+      for _, frag := range seg.Fragments {
+         samples, err := frag.GetFullSamples(trex)
+         if err != nil {
+            return nil, err
+         }
+         for _, sample := range samples {
+            start := sample.PresentationTime()
+            end := ticks(int64(start + uint64(sample.Dur)), scale)
+            if format == "stpp" {
+               err = subs.add_ttml(sample.Data, end)
+            } else {
+               err = subs.add_vtt(
+                  sample.Data, ticks(int64(start), scale), end,
+               )
+            }
+            if err != nil {
+               return nil, err
+            }
+         }
+      }
+   }
+   return &subs, nil
+}
+
+// a cue that runs across samples is joined back together
+func (s *Subtitles) add(c Cue) {
+   if n := len(s.Cues); n >= 1 {
+      last := &s.Cues[n-1]
+      if last.End == c.Start && last.Text == c.Text &&
+         last.Settings == c.Settings && last.ID == c.ID {
+         last.End = c.End
+         return
+      }
+   }
+   s.Cues = append(s.Cues, c)
+}
+
+// each vttc is a cue for the whole sample, and vtte is a sample with no cues
+func (s *Subtitles) add_vtt(data []byte, start, end time.Duration) error {
+   var pos uint64
+   r := bytes.NewReader(data)
+   for {
+      box, err := mp4.DecodeBox(pos, r)
+      if err == io.EOF {
+         return nil
+      }
+      if err != nil {
+         return err
+      }
+      if vttc, ok := box.(*mp4.VttcBox); ok && vttc.Payl != nil {
+         c := Cue{End: end, Start: start, Text: vttc.Payl.CueText}
+         if vttc.Iden != nil {
+            c.ID = vttc.Iden.CueID
+         }
+         if vttc.Sttg != nil {
+            c.Settings = vttc.Sttg.Settings
+         }
+         s.add(c)
+      }
+      pos += box.Size()
+   }
+}
+
+type ttml struct {
+   Body ttml_node `xml:"body"`
+   Frame_Rate int64 `xml:"frameRate,attr"`
+   Frame_Rate_Multiplier string `xml:"frameRateMultiplier,attr"`
+   Tick_Rate int64 `xml:"tickRate,attr"`
+}
+
+// body, div or p. Children of p are left in Inner.
+type ttml_node struct {
+   XMLName xml.Name
+   Begin string `xml:"begin,attr"`
+   Dur string `xml:"dur,attr"`
+   End string `xml:"end,attr"`
+   ID string `xml:"id,attr"`
+   Inner []byte `xml:",innerxml"`
+   Nodes []ttml_node `xml:",any"`
+}
+
+// rates from the tt parameters, and the end of the sample
+type ttml_clock struct {
+   frame float64
+   sample time.Duration
+   tick int64
+}
+
+// end of an element with no end, dur or parent end
+const indefinite = time.Duration(1<<63 - 1)
+
+func (s *Subtitles) add_ttml(data []byte, end time.Duration) error {
+   var doc ttml
+   err := xml.Unmarshal(data, &doc)
+   if err != nil {
+      return err
+   }
+   clock := ttml_clock{sample: end, tick: doc.Tick_Rate}
+   // 30 if frameRate is missing
+   clock.frame = 30
+   if doc.Frame_Rate >= 1 {
+      clock.frame = float64(doc.Frame_Rate)
+   }
+   if doc.Frame_Rate_Multiplier != "" {
+      ratio := strings.Fields(doc.Frame_Rate_Multiplier)
+      if len(ratio) != 2 {
+         return errors.New(
+            "invalid frameRateMultiplier " + doc.Frame_Rate_Multiplier,
+         )
+      }
+      num, err := strconv.ParseFloat(ratio[0], 64)
+      if err != nil {
+         return err
+      }
+      den, err := strconv.ParseFloat(ratio[1], 64)
+      if err != nil {
+         return err
+      }
+      if den == 0 {
+         return errors.New("frameRateMultiplier denominator is zero")
+      }
+      clock.frame = clock.frame * num / den
+   }
+   return s.add_ttml_node(doc.Body, 0, indefinite, clock)
+}
+
+// as with TTML2 10.4 and a timeContainer of par, begin and end of each
+// element are from the begin of its parent, and the element ends with its
+// parent at the latest. begin and end are of the parent.
+func (s *Subtitles) add_ttml_node(
+   node ttml_node, begin, end time.Duration, clock ttml_clock,
+) error {
+   parent := begin
+   if node.Begin != "" {
+      offset, err := ttml_time(node.Begin, clock)
+      if err != nil {
+         return err
+      }
+      begin = parent + offset
+   }
+   if node.End != "" {
+      offset, err := ttml_time(node.End, clock)
+      if err != nil {
+         return err
+      }
+      if parent + offset < end {
+         end = parent + offset
+      }
+   }
+   if node.Dur != "" {
+      dur, err := ttml_time(node.Dur, clock)
+      if err != nil {
+         return err
+      }
+      if begin + dur < end {
+         end = begin + dur
+      }
+   }
+   switch node.XMLName.Local {
+   case "body", "div":
+      for _, child := range node.Nodes {
+         err := s.add_ttml_node(child, begin, end, clock)
+         if err != nil {
+            return err
+         }
+      }
+   case "p":
+      if end == indefinite {
+         end = clock.sample
+      }
+      if end <= begin {
+         return nil
+      }
+      text, err := ttml_text(node.Inner)
+      if err != nil {
+         return err
+      }
+      if text != "" {
+         s.add(Cue{End: end, ID: node.ID, Start: begin, Text: text})
+      }
+   }
+   return nil
+}
+
+// clock time such as 00:00:01.500 or 00:00:01:12, with frames after the
+// seconds, or offset time such as 1.5s, 1500ms, 36f or 15000000t
+func ttml_time(s string, clock ttml_clock) (time.Duration, error) {
+   if strings.Contains(s, ":") {
+      var d time.Duration
+      for i, part := range strings.Split(s, ":") {
+         if i >= 4 {
+            return 0, errors.New("invalid time " + s)
+         }
+         value, err := strconv.ParseFloat(part, 64)
+         if err != nil {
+            return 0, err
+         }
+         if i == 3 {
+            d += time.Duration(value / clock.frame * float64(time.Second))
+         } else {
+            d = d * 60 + time.Duration(value * float64(time.Second))
+         }
+      }
+      return d, nil
+   }
+   units := []struct {
+      suffix string
+      unit float64
+   }{
+      {"ms", float64(time.Millisecond)},
+      {"h", float64(time.Hour)},
+      {"m", float64(time.Minute)},
+      {"s", float64(time.Second)},
+      {"f", float64(time.Second) / clock.frame},
+      {"t", float64(time.Second) / float64(clock.tick)},
+   }
+   for _, u := range units {
+      if strings.HasSuffix(s, u.suffix) {
+         value := strings.TrimSuffix(s, u.suffix)
+         if u.suffix == "t" && clock.tick == 0 {
+            return 0, errors.New("tick time without tickRate")
+         }
+         f, err := strconv.ParseFloat(value, 64)
+         if err != nil {
+            return 0, err
+         }
+         return time.Duration(f * u.unit), nil
+      }
+   }
+   return 0, errors.New("invalid time " + s)
+}
+
+var vtt_escape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// br is a line break, and italic or bold spans become WebVTT tags
+func ttml_text(inner []byte) (string, error) {
+   var (
+      b strings.Builder
+      closes []string
+   )
+   dec := xml.NewDecoder(bytes.NewReader(inner))
+   for {
+      tok, err := dec.Token()
+      if err == io.EOF {
+         break
+      }
+      if err != nil {
+         return "", err
+      }
+      switch tok := tok.(type) {
+      case xml.CharData:
+         text := strings.Join(strings.Fields(string(tok)), " ")
+         if text != "" && len(tok) >= 1 && is_space(tok[0]) {
+            text = " " + text
+         }
+         if text != "" && is_space(tok[len(tok)-1]) {
+            text += " "
+         }
+         // the text is WebVTT, where < starts a tag and & an entity
+         b.WriteString(vtt_escape.Replace(text))
+      case xml.StartElement:
+         var open, close string
+         switch tok.Name.Local {
+         case "br":
+            b.WriteByte('\n')
+         case "span":
+            for _, attr := range tok.Attr {
+               switch {
+               case attr.Name.Local == "fontStyle" && attr.Value == "italic":
+                  open, close = open + "<i>", "</i>" + close
+               case attr.Name.Local == "fontWeight" && attr.Value == "bold":
+                  open, close = open + "<b>", "</b>" + close
+               }
+            }
+         }
+         b.WriteString(open)
+         closes = append(closes, close)
+      case xml.EndElement:
+         if n := len(closes); n >= 1 {
+            b.WriteString(closes[n-1])
+            closes = closes[:n-1]
+         }
+      }
+   }
+   lines := strings.Split(b.String(), "\n")
+   for i, line := range lines {
+      lines[i] = strings.TrimSpace(line)
+   }
+   return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
+
+func is_space(b byte) bool {
+   return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+func (s Subtitles) WebVTT() string {
+   var b strings.Builder
+   if s.Header != "" {
+      b.WriteString(strings.TrimSpace(s.Header))
+   } else {
+      b.WriteString("WEBVTT")
+   }
+   b.WriteString("\n")
+   for _, c := range s.Cues {
+      b.WriteString("\n")
+      if c.ID != "" {
+         b.WriteString(c.ID)
+         b.WriteByte('\n')
+      }
+      b.WriteString(timestamp(c.Start, '.'))
+      b.WriteString(" --> ")
+      b.WriteString(timestamp(c.End, '.'))
+      if c.Settings != "" {
+         b.WriteByte(' ')
+         b.WriteString(c.Settings)
+      }
+      b.WriteByte('\n')
+      b.WriteString(c.Text)
+      b.WriteByte('\n')
+   }
+   return b.String()
+}
+
+// SRT has no cue settings, and only the b, i and u tags. SRT has no
+// entities either, so they are unescaped.
+func (s Subtitles) SRT() string {
+   var b strings.Builder
+   for i, c := range s.Cues {
+      if i >= 1 {
+         b.WriteByte('\n')
+      }
+      b.WriteString(strconv.Itoa(i + 1))
+      b.WriteByte('\n')
+      b.WriteString(timestamp(c.Start, ','))
+      b.WriteString(" --> ")
+      b.WriteString(timestamp(c.End, ','))
+      b.WriteByte('\n')
+      b.WriteString(srt_text(c.Text))
+      b.WriteByte('\n')
+   }
+   return b.String()
+}
+
+func srt_text(s string) string {
+   var b strings.Builder
+   for {
+      start := strings.IndexByte(s, '<')
+      if start == -1 {
+         break
+      }
+      end := strings.IndexByte(s[start:], '>')
+      if end == -1 {
+         break
+      }
+      b.WriteString(html.UnescapeString(s[:start]))
+      tag := s[start : start+end+1]
+      switch tag {
+      case "<b>", "</b>", "<i>", "</i>", "<u>", "</u>":
+         b.WriteString(tag)
+      }
+      s = s[start+end+1:]
+   }
+   b.WriteString(html.UnescapeString(s))
+   return b.String()
+}
+
+// 00:01:02.003
+func timestamp(d time.Duration, sep byte) string {
+   ms := d.Milliseconds()
+   var b []byte
+   b = append(b, two_digits(int(ms / 3600000))...)
+   b = append(b, ':')
+   b = append(b, two_digits(int(ms / 60000 % 60))...)
+   b = append(b, ':')
+   b = append(b, two_digits(int(ms / 1000 % 60))...)
+   b = append(b, sep)
+   milli := strconv.Itoa(int(ms % 1000))
+   b = append(b, strings.Repeat("0", 3 - len(milli))...)
+   b = append(b, milli...)
+   return string(b)
+}
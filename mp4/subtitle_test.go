@@ -0,0 +1,192 @@
+package mp4
+
+import (
+   "bytes"
+   "github.com/edgeware/mp4ff/mp4"
+   "testing"
+   "time"
+)
+
+func vtt_sample(boxes ...mp4.Box) []byte {
+   var buf bytes.Buffer
+   for _, box := range boxes {
+      box.Encode(&buf)
+   }
+   return buf.Bytes()
+}
+
+func vtt_cue(text, settings string) *mp4.VttcBox {
+   vttc := new(mp4.VttcBox)
+   if settings != "" {
+      vttc.AddChild(&mp4.SttgBox{Settings: settings})
+   }
+   vttc.AddChild(&mp4.PaylBox{CueText: text})
+   return vttc
+}
+
+func Test_WebVTT(t *testing.T) {
+   init := mp4.CreateEmptyInit()
+   init.AddEmptyTrack(1000, "wvtt", "en")
+   config := "WEBVTT\n\nSTYLE\n::cue { color: yellow }"
+   err := init.Moov.Trak.SetWvttDescriptor(config)
+   if err != nil {
+      t.Fatal(err)
+   }
+   var buf bytes.Buffer
+   if err := init.Encode(&buf); err != nil {
+      t.Fatal(err)
+   }
+   frag, err := mp4.CreateFragment(1, 1)
+   if err != nil {
+      t.Fatal(err)
+   }
+   samples := []struct {
+      dur uint32
+      data []byte
+   }{
+      {2000, vtt_sample(
+         vtt_cue("Hello <c.yellow><b>world</b></c>", "line:0"),
+      )},
+      {1000, vtt_sample(new(mp4.VtteBox))},
+      {1000, vtt_sample(vtt_cue("Bye", ""))},
+      // same cue, so joined with the one before
+      {1000, vtt_sample(vtt_cue("Bye", ""))},
+   }
+   var dec uint64
+   for _, sample := range samples {
+      frag.AddFullSample(mp4.FullSample{
+         Sample: mp4.Sample{
+            Dur: sample.dur, Size: uint32(len(sample.data)),
+         },
+         DecodeTime: dec,
+         Data: sample.data,
+      })
+      dec += uint64(sample.dur)
+   }
+   if err := frag.Encode(&buf); err != nil {
+      t.Fatal(err)
+   }
+   subs, err := Read_Subtitles(&buf)
+   if err != nil {
+      t.Fatal(err)
+   }
+   if len(subs.Cues) != 2 || subs.Cues[1].End != 5 * time.Second {
+      t.Fatal(subs.Cues)
+   }
+   vtt := `WEBVTT
+
+STYLE
+::cue { color: yellow }
+
+00:00:00.000 --> 00:00:02.000 line:0
+Hello <c.yellow><b>world</b></c>
+
+00:00:03.000 --> 00:00:05.000
+Bye
+`
+   if s := subs.WebVTT(); s != vtt {
+      t.Fatal(s)
+   }
+   srt := `1
+00:00:00,000 --> 00:00:02,000
+Hello <b>world</b>
+
+2
+00:00:03,000 --> 00:00:05,000
+Bye
+`
+   if s := subs.SRT(); s != srt {
+      t.Fatal(s)
+   }
+}
+
+func Test_TTML(t *testing.T) {
+   doc := `<tt xmlns="http://www.w3.org/ns/ttml"
+xmlns:tts="http://www.w3.org/ns/ttml#styling"
+xmlns:ttp="http://www.w3.org/ns/ttml#parameter" ttp:tickRate="10000000">
+<body><div>
+<p begin="00:01:02.500" end="00:01:04.000">one
+   <span tts:fontStyle="italic">two</span><br/>three</p>
+<p begin="10000000t" end="1500ms">four</p>
+</div></body></tt>`
+   var subs Subtitles
+   if err := subs.add_ttml([]byte(doc), time.Minute); err != nil {
+      t.Fatal(err)
+   }
+   if len(subs.Cues) != 2 {
+      t.Fatal(subs.Cues)
+   }
+   first := subs.Cues[0]
+   if first.Start != 62500 * time.Millisecond {
+      t.Fatal(first)
+   }
+   if first.Text != "one <i>two</i>\nthree" {
+      t.Fatal(first)
+   }
+   second := subs.Cues[1]
+   if second.Start != time.Second || second.End != 1500 * time.Millisecond {
+      t.Fatal(second)
+   }
+}
+
+func Test_TTML_Timing(t *testing.T) {
+   doc := `<tt xmlns="http://www.w3.org/ns/ttml"
+xmlns:ttp="http://www.w3.org/ns/ttml#parameter" ttp:frameRate="25">
+<body begin="1s">
+<div begin="2s" end="20s">
+   <div begin="3s">
+      <p begin="1s" dur="2s">one</p>
+      <p>two</p>
+   </div>
+   <p begin="50f" end="00:00:05:12">three</p>
+</div>
+<p begin="30s">four</p>
+</body></tt>`
+   var subs Subtitles
+   if err := subs.add_ttml([]byte(doc), 40 * time.Second); err != nil {
+      t.Fatal(err)
+   }
+   want := []Cue{
+      // 1 + 2 + 3 + 1, then dur
+      {Start: 7 * time.Second, End: 9 * time.Second, Text: "one"},
+      // begin of the inner div, and end of the outer div, 1 + 20
+      {Start: 6 * time.Second, End: 21 * time.Second, Text: "two"},
+      // 50 frames is 2 seconds, and 12 frames is 480ms
+      {Start: 5 * time.Second, End: 8480 * time.Millisecond, Text: "three"},
+      // end of the sample
+      {Start: 31 * time.Second, End: 40 * time.Second, Text: "four"},
+   }
+   if len(subs.Cues) != len(want) {
+      t.Fatal(subs.Cues)
+   }
+   for i, c := range want {
+      if subs.Cues[i] != c {
+         t.Fatal(subs.Cues[i])
+      }
+   }
+}
+
+// entities stay escaped in WebVTT, and SRT gets the characters back
+func Test_TTML_Escape(t *testing.T) {
+   doc := `<tt xmlns="http://www.w3.org/ns/ttml"
+xmlns:tts="http://www.w3.org/ns/ttml#styling">
+<body><div>
+<p begin="1s" end="2s">x &lt; y &amp;&amp; y &gt; z
+   <span tts:fontStyle="italic">done</span></p>
+</div></body></tt>`
+   var subs Subtitles
+   if err := subs.add_ttml([]byte(doc), time.Minute); err != nil {
+      t.Fatal(err)
+   }
+   if len(subs.Cues) != 1 {
+      t.Fatal(subs.Cues)
+   }
+   text := subs.Cues[0].Text
+   if text != "x &lt; y &amp;&amp; y &gt; z <i>done</i>" {
+      t.Fatal(text)
+   }
+   srt := "1\n00:00:01,000 --> 00:00:02,000\nx < y && y > z <i>done</i>\n"
+   if s := subs.SRT(); s != srt {
+      t.Fatal(s)
+   }
+}
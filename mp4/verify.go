@@ -0,0 +1,299 @@
+package mp4
+
+import (
+   "github.com/edgeware/mp4ff/mp4"
+   "strconv"
+)
+
+// how many samples of each traf are checked
+const verify_samples = 4
+
+// decrypted sample does not have the structure of its codec
+type Verify_Error struct {
+   Reason string
+   Sample int
+   Track uint32
+}
+
+func (v Verify_Error) Error() string {
+   var b []byte
+   b = append(b, "probably wrong key, track "...)
+   b = strconv.AppendUint(b, uint64(v.Track), 10)
+   b = append(b, " sample "...)
+   b = strconv.AppendInt(b, int64(v.Sample), 10)
+   b = append(b, ": "...)
+   b = append(b, v.Reason...)
+   return string(b)
+}
+
+// what verify needs from the sample entry
+type verify_entry struct {
+   format string
+   // NAL length size
+   size int
+   // mp4a with raw_data_block
+   aac bool
+}
+
+// AAC LC, HE-AAC and HE-AACv2. Others in mp4a, such as MP3 or USAC, do not
+// have raw_data_block.
+func aac_entry(entry *mp4.AudioSampleEntryBox) bool {
+   switch audio_codec(entry) {
+   case "mp4a.40.2", "mp4a.40.5", "mp4a.40.29":
+      return true
+   }
+   return false
+}
+
+// formats other than AVC, HEVC and AAC are not checked
+func verify(track uint32, entry verify_entry, jobs []sample_job) error {
+   for i, job := range jobs {
+      if i >= verify_samples {
+         break
+      }
+      var reason string
+      switch entry.format {
+      case "avc1", "avc3", "hev1", "hvc1":
+         reason = verify_nal(entry.format, entry.size, job.data)
+      case "mp4a":
+         if entry.aac {
+            reason = verify_aac(job.data)
+         }
+      }
+      if reason != "" {
+         return Verify_Error{reason, job.index, track}
+      }
+   }
+   return nil
+}
+
+type bit_reader struct {
+   data []byte
+   pos int
+}
+
+func (b *bit_reader) read(n int) (uint32, bool) {
+   var value uint32
+   for i := 0; i < n; i++ {
+      if b.pos >= len(b.data) * 8 {
+         return 0, false
+      }
+      bit := b.data[b.pos / 8] >> (7 - b.pos % 8) & 1
+      value = value << 1 | uint32(bit)
+      b.pos++
+   }
+   return value, true
+}
+
+// Exp-Golomb
+func (b *bit_reader) ue() (uint32, bool) {
+   var zeros int
+   for {
+      bit, ok := b.read(1)
+      if !ok || zeros > 31 {
+         return 0, false
+      }
+      if bit == 1 {
+         break
+      }
+      zeros++
+   }
+   rest, ok := b.read(zeros)
+   if !ok {
+      return 0, false
+   }
+   return 1 << zeros - 1 + rest, true
+}
+
+// lengths of size bytes that add up to the sample, with valid NAL headers.
+// The lengths and headers are clear with subsample encryption, so slice
+// headers are checked as well.
+func verify_nal(format string, size int, sample []byte) string {
+   hevc := format == "hev1" || format == "hvc1"
+   for len(sample) >= 1 {
+      if len(sample) <= size {
+         return "NAL length past end of sample"
+      }
+      length := nal_length(sample, size)
+      if length == 0 || length > len(sample) - size {
+         return "NAL length past end of sample"
+      }
+      nal := sample[size : size+length]
+      if nal[0] & 0x80 != 0 {
+         return "NAL forbidden_zero_bit is set"
+      }
+      var reason string
+      if hevc {
+         reason = verify_hevc(nal)
+      } else {
+         reason = verify_avc(nal)
+      }
+      if reason != "" {
+         return reason
+      }
+      sample = sample[size+length:]
+   }
+   return ""
+}
+
+func verify_avc(nal []byte) string {
+   kind := nal[0] & 0x1F
+   if kind == 0 {
+      return "NAL type 0"
+   }
+   if kind != 1 && kind != 5 {
+      return ""
+   }
+   r := bit_reader{data: nal[1:]}
+   // first_mb_in_slice, 8K is 139264 macroblocks
+   first, ok := r.ue()
+   if !ok || first >= 139264 {
+      return "invalid first_mb_in_slice"
+   }
+   slice_type, ok := r.ue()
+   if !ok || slice_type > 9 {
+      return "invalid slice_type"
+   }
+   // IDR is I or SI only
+   if kind == 5 {
+      switch slice_type % 5 {
+      case 2, 4:
+      default:
+         return "IDR slice is not I or SI"
+      }
+   }
+   if pps, ok := r.ue(); !ok || pps > 255 {
+      return "invalid pic_parameter_set_id"
+   }
+   return ""
+}
+
+func verify_hevc(nal []byte) string {
+   if len(nal) < 2 {
+      return "NAL header past end of NAL"
+   }
+   // nuh_temporal_id_plus1
+   if nal[1] & 7 == 0 {
+      return "NAL nuh_temporal_id_plus1 is zero"
+   }
+   kind := nal[0] >> 1 & 0x3F
+   if kind > 31 {
+      return ""
+   }
+   r := bit_reader{data: nal[2:]}
+   // first_slice_segment_in_pic_flag
+   if _, ok := r.read(1); !ok {
+      return "slice header past end of NAL"
+   }
+   // IRAP
+   if kind >= 16 && kind <= 23 {
+      // no_output_of_prior_pics_flag
+      r.read(1)
+   }
+   if pps, ok := r.ue(); !ok || pps > 63 {
+      return "invalid slice_pic_parameter_set_id"
+   }
+   return ""
+}
+
+// raw_data_block, optionally after an ADTS header. Data and fill elements are
+// skipped, then the first audio element is checked.
+func verify_aac(sample []byte) string {
+   if len(sample) >= 2 && sample[0] == 0xFF && sample[1] & 0xF6 == 0xF0 {
+      // CRC after the header, without protection_absent
+      header := 9
+      if sample[1] & 1 == 1 {
+         header = 7
+      }
+      if len(sample) < header {
+         return "ADTS header past end of sample"
+      }
+      length := int(sample[3] & 3) << 11 | int(sample[4]) << 3 |
+         int(sample[5]) >> 5
+      if length != len(sample) {
+         return "ADTS frame_length is not the sample size"
+      }
+      sample = sample[header:]
+   }
+   r := bit_reader{data: sample}
+   for {
+      id, ok := r.read(3)
+      if !ok {
+         return "no audio element"
+      }
+      switch id {
+      case 0, 1, 3: // SCE, CPE, LFE
+         return verify_ics(&r, id)
+      case 4: // DSE
+         // element_instance_tag
+         r.read(4)
+         align, _ := r.read(1)
+         count, _ := r.read(8)
+         if count == 255 {
+            esc, _ := r.read(8)
+            count += esc
+         }
+         if align == 1 {
+            r.pos = (r.pos + 7) &^ 7
+         }
+         r.pos += int(count) * 8
+      case 6: // FIL
+         count, _ := r.read(4)
+         if count == 15 {
+            esc, _ := r.read(8)
+            count += esc - 1
+         }
+         r.pos += int(count) * 8
+      default:
+         return "first element is not SCE, CPE, LFE, DSE or FIL"
+      }
+   }
+}
+
+// start of single_channel_element or channel_pair_element, up to the end of
+// ics_info. AAC LC has no prediction.
+func verify_ics(r *bit_reader, id uint32) string {
+   // element_instance_tag
+   r.read(4)
+   var common uint32
+   if id == 1 {
+      common, _ = r.read(1)
+   }
+   if common == 0 {
+      // global_gain
+      r.read(8)
+   }
+   reserved, ok := r.read(1)
+   if !ok || reserved != 0 {
+      return "ics_reserved_bit is set"
+   }
+   sequence, _ := r.read(2)
+   // window_shape
+   r.read(1)
+   // EIGHT_SHORT_SEQUENCE has 15 bands at most
+   if sequence == 2 {
+      max_sfb, ok := r.read(4)
+      if !ok || max_sfb > 15 {
+         return "invalid max_sfb"
+      }
+      // scale_factor_grouping
+      r.read(7)
+   } else {
+      // 51 bands at most, for 8 kHz
+      max_sfb, ok := r.read(6)
+      if !ok || max_sfb > 51 {
+         return "invalid max_sfb"
+      }
+      predictor, ok := r.read(1)
+      if !ok || predictor != 0 {
+         return "predictor_data_present is set"
+      }
+   }
+   if common == 1 {
+      ms_mask, ok := r.read(2)
+      if !ok || ms_mask == 3 {
+         return "reserved ms_mask_present"
+      }
+   }
+   return ""
+}
@@ -0,0 +1,125 @@
+package mp4
+
+import (
+   "bytes"
+   "errors"
+   "github.com/edgeware/mp4ff/mp4"
+   "testing"
+)
+
+// CPE with common_window, and max_sfb of 4
+var aac_frame = []byte{0x21, 0x01, 0x00}
+
+func Test_Verify_NAL(t *testing.T) {
+   for _, size := range []int{1, 2, 4} {
+      idr := length_nal(size, []byte{0x65, 0x88, 0x80})
+      if reason := verify_nal("avc1", size, idr); reason != "" {
+         t.Fatal(size, reason)
+      }
+      // P slice
+      idr[size+1] = 0xE0
+      reason := verify_nal("avc1", size, idr)
+      if reason != "IDR slice is not I or SI" {
+         t.Fatal(size, reason)
+      }
+      idr[size-1] = 9
+      if reason := verify_nal("avc1", size, idr); reason == "" {
+         t.Fatal(size, "length")
+      }
+   }
+}
+
+func Test_Verify_AAC(t *testing.T) {
+   if reason := verify_aac(aac_frame); reason != "" {
+      t.Fatal(reason)
+   }
+   // ADTS header, protection_absent, frame_length 10
+   adts := []byte{0xFF, 0xF1, 0x50, 0x80, 0x01, 0x40, 0xFC}
+   adts = append(adts, aac_frame...)
+   if reason := verify_aac(adts); reason != "" {
+      t.Fatal(reason)
+   }
+   // CCE
+   if reason := verify_aac([]byte{0x40, 0, 0}); reason == "" {
+      t.Fatal("CCE")
+   }
+   // ADTS with CRC, and frame_length 8, so the header is past the end
+   crc := []byte{0xFF, 0xF0, 0, 0, 1, 0, 0, 0}
+   if reason := verify_aac(crc); reason == "" {
+      t.Fatal("CRC")
+   }
+}
+
+func Test_AAC_Entry(t *testing.T) {
+   tests := []struct {
+      object byte
+      config []byte
+      aac bool
+   }{
+      {0x40, []byte{0x12, 0x10}, true},
+      // HE-AAC
+      {0x40, []byte{0x2B, 0x10}, true},
+      // USAC, with the escape value
+      {0x40, []byte{0xF9, 0x40}, false},
+      // MP3
+      {0x6B, nil, false},
+   }
+   for _, test := range tests {
+      esds := mp4.CreateEsdsBox(test.config)
+      esds.DecConfigDescriptor.ObjectType = test.object
+      entry := mp4.CreateAudioSampleEntryBox("mp4a", 2, 16, 48000, esds)
+      if aac_entry(entry) != test.aac {
+         t.Fatal(test)
+      }
+   }
+   // garbage is only checked as AAC
+   jobs := []sample_job{{data: []byte{0x40, 0, 0}}}
+   if err := verify(1, verify_entry{format: "mp4a"}, jobs); err != nil {
+      t.Fatal(err)
+   }
+   err := verify(1, verify_entry{format: "mp4a", aac: true}, jobs)
+   if err == nil {
+      t.Fatal("AAC")
+   }
+}
+
+func Test_Verify(t *testing.T) {
+   key := bytes.Repeat([]byte{6}, 16)
+   _, file, err := fixture{
+      handler: "audio",
+      timescale: 48000,
+      duration: 1024,
+      segments: 1,
+      fragments: 1,
+      samples: 8,
+      data: func(_, sample int) []byte {
+         data := append([]byte{}, aac_frame...)
+         return append(data, bytes.Repeat([]byte{byte(sample)}, 97)...)
+      },
+   }.encrypt("cbcs", key)
+   if err != nil {
+      t.Fatal(err)
+   }
+   for _, test := range []struct {
+      key []byte
+      ok bool
+   }{
+      {key, true},
+      {bytes.Repeat([]byte{7}, 16), false},
+   } {
+      dec := New_Decrypt(new(bytes.Buffer))
+      dec.Verify = true
+      if err := dec.Init(bytes.NewReader(file)); err != nil {
+         t.Fatal(err)
+      }
+      err := dec.Segment(bytes.NewReader(file), test.key)
+      var verify_err Verify_Error
+      if test.ok {
+         if err != nil {
+            t.Fatal(err)
+         }
+      } else if !errors.As(err, &verify_err) {
+         t.Fatal(err)
+      }
+   }
+}
@@ -0,0 +1,75 @@
+package mp4
+
+import (
+   "bytes"
+   "io"
+   "runtime"
+   "testing"
+)
+
+var worker_key = bytes.Repeat([]byte{5}, 16)
+
+// cenc track, with fragments of 32 samples
+func encrypted_fixture(frags, size int) ([]byte, error) {
+   _, file, err := fixture{
+      handler: "audio",
+      timescale: 48000,
+      duration: 1024,
+      segments: frags,
+      fragments: 1,
+      samples: 32,
+      data: func(seq, sample int) []byte {
+         return bytes.Repeat([]byte{byte(seq), byte(sample)}, size / 2)
+      },
+   }.encrypt("cenc", worker_key)
+   return file, err
+}
+
+func decrypt_fixture(w io.Writer, workers int, file []byte) error {
+   dec := New_Decrypt(w)
+   dec.Workers = workers
+   if err := dec.Init(bytes.NewReader(file)); err != nil {
+      return err
+   }
+   return dec.Segment(bytes.NewReader(file), worker_key)
+}
+
+func Test_Workers(t *testing.T) {
+   file, err := encrypted_fixture(4, 1000)
+   if err != nil {
+      t.Fatal(err)
+   }
+   var serial, parallel bytes.Buffer
+   if err := decrypt_fixture(&serial, 1, file); err != nil {
+      t.Fatal(err)
+   }
+   if err := decrypt_fixture(&parallel, 8, file); err != nil {
+      t.Fatal(err)
+   }
+   if !bytes.Equal(serial.Bytes(), parallel.Bytes()) {
+      t.Fatal(serial.Len(), parallel.Len())
+   }
+}
+
+func benchmark_workers(b *testing.B, workers int) {
+   file, err := encrypted_fixture(8, 256 * 1024)
+   if err != nil {
+      b.Fatal(err)
+   }
+   b.SetBytes(int64(len(file)))
+   b.ResetTimer()
+   for i := 0; i < b.N; i++ {
+      err := decrypt_fixture(io.Discard, workers, file)
+      if err != nil {
+         b.Fatal(err)
+      }
+   }
+}
+
+func Benchmark_Serial(b *testing.B) {
+   benchmark_workers(b, 1)
+}
+
+func Benchmark_Parallel(b *testing.B) {
+   benchmark_workers(b, runtime.NumCPU())
+}